@@ -1,6 +1,7 @@
 package cache
 
 import (
+	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
@@ -10,6 +11,7 @@ import (
 	"operarius/pkg/git_wrapper"
 	"os"
 	"sync"
+	"time"
 
 	"github.com/go-extras/tahwil"
 	"github.com/jinzhu/copier"
@@ -31,9 +33,14 @@ type LRUCache struct {
 	SizeLimit uint64 `json:"sizeLimit"`
 	// path cache_metadata.json
 	Path string `json:"path"`
+
+	leases       map[string]map[uint64]*Lease
+	leaseSeq     uint64
+	leaseChanged chan struct{}
 }
 
 type Node struct {
+	Key  string                      `json:"key"`
 	Next *Node                       `json:"next"`
 	Prev *Node                       `json:"prev"`
 	Val  *models.RepositoryConcreate `json:"val"`
@@ -84,27 +91,127 @@ func (c *LRUCache) Get(key string) *models.RepositoryConcreate {
 }
 
 func (c *LRUCache) Put(key string, val *models.RepositoryConcreate) {
+	// measured on a copy, val may be the stored entry whose size is accounted
+	measured := &models.RepositoryConcreate{RootRepository: val.RootRepository, Size: val.Size}
+	c.measure(measured)
 	lock.Lock()
 	defer lock.Unlock()
 	if node, found := c.Mapcache[key]; found {
 		repository := node.Val
 		c.removeNode(key)
+		repository.Size = measured.Size
+		val.Size = measured.Size
 		c.addHead(key, repository)
 	} else {
+		val.Size = measured.Size
 		c.addHead(key, val)
 	}
-	// for c.CurrentSize > c.SizeLimit {
-	// 	log.Println("Remove worktree")
-	// 	if c.Tail == nil {
-	// 		break
-	// 	}
-	// 	c.Tail.Val.RootRepository.RemoveRepository()
-	// 	log.Println(c.Tail.Val)
-	// 	c.removeTail()
-	// }
+	c.evict()
+	c.Sync(c.Path)
+}
+
+// Refresh re-measures the repository stored under key. Fetch and AddWorktree
+// call it, callers growing the repository on disk by other means should too.
+func (c *LRUCache) Refresh(key string) {
+	lock.Lock()
+	node, found := c.Mapcache[key]
+	lock.Unlock()
+	if !found || node == nil {
+		return
+	}
+	val := &models.RepositoryConcreate{RootRepository: node.Val.RootRepository, Size: node.Val.Size}
+	c.measure(val)
+	lock.Lock()
+	defer lock.Unlock()
+	if current, found := c.Mapcache[key]; !found || current != node {
+		return
+	}
+	c.decreaseSize(node.Val.Size)
+	node.Val.Size = val.Size
+	c.CurrentSize += node.Val.Size
+	c.evict()
 	c.Sync(c.Path)
 }
 
+// Fetch fetches the repository stored under key and re-measures it. The
+// entry is pinned meanwhile so it can't be evicted.
+func (c *LRUCache) Fetch(key string) error {
+	return c.FetchContext(context.Background(), key)
+}
+
+func (c *LRUCache) FetchContext(ctx context.Context, key string) error {
+	lease, err := c.Acquire(key, 0)
+	if err != nil {
+		return err
+	}
+	defer lease.Release()
+	if err := lease.Repository.RootRepository.FetchContext(ctx); err != nil {
+		return err
+	}
+	c.Refresh(key)
+	return nil
+}
+
+// AddWorktree adds a worktree to the repository stored under key and
+// re-measures it. The entry is pinned meanwhile so it can't be evicted.
+func (c *LRUCache) AddWorktree(key string, path string, commitSHA string) (*git_wrapper.Worktree, error) {
+	return c.AddWorktreeContext(context.Background(), key, path, commitSHA)
+}
+
+func (c *LRUCache) AddWorktreeContext(ctx context.Context, key string, path string, commitSHA string) (*git_wrapper.Worktree, error) {
+	lease, err := c.Acquire(key, 0)
+	if err != nil {
+		return nil, err
+	}
+	defer lease.Release()
+	worktree, err := lease.Repository.RootRepository.AddWorktreeContext(ctx, path, commitSHA)
+	if err != nil {
+		return nil, err
+	}
+	c.Refresh(key)
+	return worktree, nil
+}
+
+func (c *LRUCache) measure(val *models.RepositoryConcreate) {
+	if val == nil || val.RootRepository == nil {
+		return
+	}
+	size, err := repositorySizeFunc(context.Background(), val.RootRepository)
+	if err != nil {
+		log.Println("measure repository size fail", err)
+		return
+	}
+	val.Size = size
+}
+
+// evict drops least recently used repositories from disk until the cache fits
+// in SizeLimit. The most recently used entry and entries pinned by a lease are
+// always kept, even if the cache stays above the limit.
+func (c *LRUCache) evict() {
+	now := time.Now()
+	cur := c.Tail
+	for c.CurrentSize > c.SizeLimit && cur != nil && cur != c.Head {
+		prev := cur.Prev
+		if c.isPinned(cur.Key, now) {
+			cur = prev
+			continue
+		}
+		log.Printf("Evict repository %s, cache size %d KB exceeds limit %d KB", cur.Key, c.CurrentSize, c.SizeLimit)
+		if cur.Val.RootRepository != nil {
+			if err := cur.Val.RootRepository.RemoveRepository(); err != nil {
+				log.Println("remove repository fail", err)
+			}
+		}
+		if cur == c.Tail {
+			c.removeTail()
+		} else {
+			// the entries after cur are pinned
+			c.removeNode(cur.Key)
+		}
+		cur = prev
+	}
+}
+
 func (c *LRUCache) removeNode(key string) {
 	node, found := c.Mapcache[key]
 	if !found || node == nil {
@@ -119,32 +226,47 @@ func (c *LRUCache) removeNode(key string) {
 		nPrev.Next = nNext
 	}
 	if c.Head == node {
-		c.Head = c.Head.Next
+		c.Head = nNext
 	}
 	if c.Tail == node {
-		c.Tail.Prev = nil
+		c.Tail = nPrev
 	}
+	node.Next = nil
+	node.Prev = nil
 	// Remove cache from in-memory
 	delete(c.Mapcache, key)
-	c.CurrentSize -= node.Val.Size
+	c.decreaseSize(node.Val.Size)
+}
+
+func (c *LRUCache) decreaseSize(size uint64) {
+	if size > c.CurrentSize {
+		c.CurrentSize = 0
+		return
+	}
+	c.CurrentSize -= size
 }
 
-func (c *LRUCache) RemoveNodeLock(key string) {
+func (c *LRUCache) RemoveNodeLock(key string) error {
 	lock.Lock()
 	defer lock.Unlock()
 	node, found := c.Mapcache[key]
 	if !found || node == nil {
-		return
+		return nil
+	}
+	if c.isPinned(key, time.Now()) {
+		return ErrEntryPinned
 	}
 	// Remove root source from node disk
 	node.Val.RootRepository.RemoveRepository()
 	c.removeNode(key)
 	c.Sync(c.Path)
+	return nil
 }
 
 func (c *LRUCache) addHead(key string, val *models.RepositoryConcreate) {
 	if c.Head != nil {
 		newNode := &Node{
+			Key:  key,
 			Next: c.Head,
 			Val:  val,
 		}
@@ -152,6 +274,7 @@ func (c *LRUCache) addHead(key string, val *models.RepositoryConcreate) {
 		c.Head = newNode
 	} else {
 		newNode := &Node{
+			Key: key,
 			Val: val,
 		}
 		c.Head = newNode
@@ -161,14 +284,11 @@ func (c *LRUCache) addHead(key string, val *models.RepositoryConcreate) {
 	c.CurrentSize += val.Size
 }
 
+// removeTail drops the least recently used entry from the list and the map,
+// and its size from CurrentSize.
 func (c *LRUCache) removeTail() {
 	if c.Tail != nil {
-		log.Println(c.Tail)
-		c.CurrentSize -= c.Tail.Val.Size
-		if c.Tail.Prev != nil {
-			c.Tail = c.Tail.Prev
-			c.Tail.Next = nil
-		}
+		c.removeNode(c.Tail.Key)
 	}
 }
 
@@ -214,7 +334,11 @@ func RebuildCacheFromFile(path string, sizeLimit uint64) *LRUCache {
 	}
 	cur := head
 	for cur != nil {
-		lruCache.Mapcache[fmt.Sprintf("%s-%s", cur.Val.Provider, cur.Val.ProviderInternalId)] = cur
+		if cur.Key == "" {
+			cur.Key = fmt.Sprintf("%s-%s", cur.Val.Provider, cur.Val.ProviderInternalId)
+		}
+		lruCache.Mapcache[cur.Key] = cur
+		lruCache.measure(cur.Val)
 		lruCache.CurrentSize += cur.Val.Size
 		if cur.Next == nil {
 			break
@@ -223,6 +347,7 @@ func RebuildCacheFromFile(path string, sizeLimit uint64) *LRUCache {
 	}
 	lruCache.Head = head
 	lruCache.Tail = cur
+	lruCache.evict()
 	return lruCache
 }
 
@@ -0,0 +1,257 @@
+package cache
+
+import (
+	"context"
+	"operarius/internal/models"
+	"operarius/pkg/git_wrapper"
+	mock_git_wrapper "operarius/pkg/git_wrapper/mock"
+	"os"
+	"path/filepath"
+
+	"github.com/golang/mock/gomock"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+func keys(c *LRUCache) []string {
+	result := []string{}
+	for cur := c.Head; cur != nil; cur = cur.Next {
+		result = append(result, cur.Key)
+	}
+	return result
+}
+
+func reverseKeys(c *LRUCache) []string {
+	result := []string{}
+	for cur := c.Tail; cur != nil; cur = cur.Prev {
+		result = append(result, cur.Key)
+	}
+	return result
+}
+
+// stubRepositorySize makes repositories measure to their size in the returned
+// map until the end of the spec.
+func stubRepositorySize() map[git_wrapper.IRepository]uint64 {
+	sizes := map[git_wrapper.IRepository]uint64{}
+	old := repositorySizeFunc
+	repositorySizeFunc = func(ctx context.Context, repository git_wrapper.IRepository) (uint64, error) {
+		return sizes[repository], nil
+	}
+	DeferCleanup(func() { repositorySizeFunc = old })
+	return sizes
+}
+
+var _ = Describe("LRUCache unit test", func() {
+	var mockCtrl *gomock.Controller
+	var cache *LRUCache
+	var sizes map[git_wrapper.IRepository]uint64
+	BeforeEach(func() {
+		mockCtrl = gomock.NewController(GinkgoT())
+		cache = NewLRUCache(100)
+		cache.Path = filepath.Join(GinkgoT().TempDir(), "cache_metadata.json")
+		sizes = stubRepositorySize()
+	})
+
+	newRepository := func(size uint64) (*models.RepositoryConcreate, *mock_git_wrapper.MockIRepository) {
+		repository := mock_git_wrapper.NewMockIRepository(mockCtrl)
+		sizes[repository] = size
+		return &models.RepositoryConcreate{
+			Size:           size,
+			RootRepository: repository,
+		}, repository
+	}
+
+	Context("removeNode(key string)", func() {
+		BeforeEach(func() {
+			for _, key := range []string{"a", "b", "c"} {
+				repository, _ := newRepository(10)
+				cache.Put(key, repository)
+			}
+			Expect(keys(cache)).To(Equal([]string{"c", "b", "a"}))
+		})
+
+		It("Should remove the head node", func() {
+			cache.removeNode("c")
+			Expect(keys(cache)).To(Equal([]string{"b", "a"}))
+			Expect(reverseKeys(cache)).To(Equal([]string{"a", "b"}))
+			Expect(cache.Mapcache).NotTo(HaveKey("c"))
+			Expect(cache.CurrentSize).To(Equal(uint64(20)))
+		})
+
+		It("Should remove the tail node", func() {
+			cache.removeNode("a")
+			Expect(keys(cache)).To(Equal([]string{"c", "b"}))
+			Expect(reverseKeys(cache)).To(Equal([]string{"b", "c"}))
+			Expect(cache.Mapcache).NotTo(HaveKey("a"))
+			Expect(cache.CurrentSize).To(Equal(uint64(20)))
+		})
+
+		It("Should remove a middle node", func() {
+			cache.removeNode("b")
+			Expect(keys(cache)).To(Equal([]string{"c", "a"}))
+			Expect(reverseKeys(cache)).To(Equal([]string{"a", "c"}))
+			Expect(cache.Mapcache).NotTo(HaveKey("b"))
+			Expect(cache.CurrentSize).To(Equal(uint64(20)))
+		})
+
+		It("Should empty the list when the last node is removed", func() {
+			cache.removeNode("a")
+			cache.removeNode("b")
+			cache.removeNode("c")
+			Expect(cache.Head).To(BeNil())
+			Expect(cache.Tail).To(BeNil())
+			Expect(cache.Mapcache).To(BeEmpty())
+			Expect(cache.CurrentSize).To(BeZero())
+		})
+	})
+
+	Context("removeTail()", func() {
+		It("Should remove the tail and its map entry", func() {
+			for _, key := range []string{"a", "b"} {
+				repository, _ := newRepository(10)
+				cache.Put(key, repository)
+			}
+			cache.removeTail()
+			Expect(keys(cache)).To(Equal([]string{"b"}))
+			Expect(cache.Tail.Key).To(Equal("b"))
+			Expect(cache.Mapcache).NotTo(HaveKey("a"))
+			Expect(cache.CurrentSize).To(Equal(uint64(10)))
+
+			cache.removeTail()
+			Expect(cache.Head).To(BeNil())
+			Expect(cache.Tail).To(BeNil())
+			Expect(cache.Mapcache).To(BeEmpty())
+			Expect(cache.CurrentSize).To(BeZero())
+		})
+	})
+
+	Context("Put(key string, val *models.RepositoryConcreate)", func() {
+		It("Should evict least recently used repositories until the size limit is honoured", func() {
+			a, repositoryA := newRepository(40)
+			b, repositoryB := newRepository(40)
+			c, repositoryC := newRepository(40)
+			cache.Put("a", a)
+			cache.Put("b", b)
+			repositoryA.EXPECT().RemoveRepository().Return(nil).Times(1)
+			cache.Put("c", c)
+			Expect(keys(cache)).To(Equal([]string{"c", "b"}))
+			Expect(cache.Mapcache).NotTo(HaveKey("a"))
+			Expect(cache.CurrentSize).To(Equal(uint64(80)))
+
+			cache.Get("b")
+			d, _ := newRepository(30)
+			repositoryB.EXPECT().RemoveRepository().Times(0)
+			repositoryC.EXPECT().RemoveRepository().Return(nil).Times(1)
+			cache.Put("d", d)
+			Expect(keys(cache)).To(Equal([]string{"d", "b"}))
+			Expect(cache.CurrentSize).To(Equal(uint64(70)))
+		})
+
+		It("Should replace the size of an existing key", func() {
+			a, repositoryA := newRepository(10)
+			b, _ := newRepository(10)
+			cache.Put("a", a)
+			cache.Put("b", b)
+			sizes[repositoryA] = 50
+			cache.Put("a", a)
+			Expect(keys(cache)).To(Equal([]string{"a", "b"}))
+			Expect(cache.CurrentSize).To(Equal(uint64(60)))
+		})
+
+		It("Should keep the most recently used repository even if it exceeds the limit", func() {
+			a, _ := newRepository(150)
+			cache.Put("a", a)
+			Expect(keys(cache)).To(Equal([]string{"a"}))
+			Expect(cache.CurrentSize).To(Equal(uint64(150)))
+		})
+	})
+
+	Context("Refresh(key string)", func() {
+		It("Should re-measure the repository and evict when it grew past the limit", func() {
+			a, repositoryA := newRepository(30)
+			b, repositoryB := newRepository(30)
+			cache.Put("a", a)
+			cache.Put("b", b)
+			Expect(cache.CurrentSize).To(Equal(uint64(60)))
+
+			sizes[repositoryB] = 80
+			repositoryA.EXPECT().RemoveRepository().Return(nil).Times(1)
+			cache.Refresh("b")
+			Expect(keys(cache)).To(Equal([]string{"b"}))
+			Expect(b.Size).To(Equal(uint64(80)))
+			Expect(cache.CurrentSize).To(Equal(uint64(80)))
+		})
+	})
+
+	Context("FetchContext(ctx context.Context, key string) error", func() {
+		It("Should fetch and re-measure the repository", func() {
+			a, repositoryA := newRepository(30)
+			cache.Put("a", a)
+			repositoryA.EXPECT().FetchContext(gomock.Any()).DoAndReturn(func(ctx context.Context) error {
+				Expect(cache.IsPinned("a")).To(BeTrue())
+				sizes[repositoryA] = 50
+				return nil
+			})
+			Expect(cache.Fetch("a")).To(Succeed())
+			Expect(a.Size).To(Equal(uint64(50)))
+			Expect(cache.CurrentSize).To(Equal(uint64(50)))
+			Expect(cache.IsPinned("a")).To(BeFalse())
+			Expect(cache.Fetch("missing")).To(Equal(ErrEntryNotFound))
+		})
+	})
+
+	Context("AddWorktreeContext(ctx context.Context, key string, path string, commitSHA string) (*git_wrapper.Worktree, error)", func() {
+		It("Should add the worktree and re-measure the repository", func() {
+			a, repositoryA := newRepository(30)
+			cache.Put("a", a)
+			worktree := git_wrapper.NewWorkTree("/tmp/a-sha", "sha")
+			repositoryA.EXPECT().AddWorktreeContext(gomock.Any(), "/tmp/a-sha", "sha").DoAndReturn(func(ctx context.Context, path string, commitSHA string) (*git_wrapper.Worktree, error) {
+				sizes[repositoryA] = 40
+				return &worktree, nil
+			})
+			result, err := cache.AddWorktree("a", "/tmp/a-sha", "sha")
+			Expect(err).To(BeNil())
+			Expect(result).To(Equal(&worktree))
+			Expect(cache.CurrentSize).To(Equal(uint64(40)))
+		})
+	})
+
+	Context("RepositorySize(ctx context.Context, repository git_wrapper.IRepository) (uint64, error)", func() {
+		It("Should add the object store size to the worktree files", func() {
+			mainPath := GinkgoT().TempDir()
+			linkedPath := GinkgoT().TempDir()
+			Expect(os.MkdirAll(filepath.Join(mainPath, ".git"), os.ModePerm)).To(Succeed())
+			Expect(os.WriteFile(filepath.Join(mainPath, ".git", "packed"), make([]byte, 4096), 0644)).To(Succeed())
+			Expect(os.WriteFile(filepath.Join(mainPath, "main.go"), make([]byte, 2048), 0644)).To(Succeed())
+			Expect(os.WriteFile(filepath.Join(linkedPath, "main.go"), make([]byte, 1024), 0644)).To(Succeed())
+
+			repository := mock_git_wrapper.NewMockIRepository(mockCtrl)
+			repository.EXPECT().CountObjectsContext(gomock.Any()).Return(&git_wrapper.ObjectCount{Size: 1, SizePack: 10}, nil)
+			repository.EXPECT().GetWorktrees().Return([]git_wrapper.Worktree{
+				{Path: mainPath, IsMain: true},
+				{Path: linkedPath},
+			})
+			size, err := RepositorySize(context.Background(), repository)
+			Expect(err).To(BeNil())
+			Expect(size).To(Equal(uint64(14)))
+		})
+
+		It("Should count worktrees nested in another one once", func() {
+			mainPath := GinkgoT().TempDir()
+			nestedPath := filepath.Join(mainPath, "worktrees", "sha")
+			Expect(os.MkdirAll(nestedPath, os.ModePerm)).To(Succeed())
+			Expect(os.WriteFile(filepath.Join(mainPath, "main.go"), make([]byte, 2048), 0644)).To(Succeed())
+			Expect(os.WriteFile(filepath.Join(nestedPath, "main.go"), make([]byte, 1024), 0644)).To(Succeed())
+
+			repository := mock_git_wrapper.NewMockIRepository(mockCtrl)
+			repository.EXPECT().CountObjectsContext(gomock.Any()).Return(&git_wrapper.ObjectCount{}, nil)
+			repository.EXPECT().GetWorktrees().Return([]git_wrapper.Worktree{
+				{Path: nestedPath},
+				{Path: mainPath, IsMain: true},
+			})
+			size, err := RepositorySize(context.Background(), repository)
+			Expect(err).To(BeNil())
+			Expect(size).To(Equal(uint64(3)))
+		})
+	})
+})
@@ -0,0 +1,204 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"operarius/internal/models"
+	"time"
+)
+
+var (
+	ErrEntryNotFound = errors.New("cache entry not found")
+	ErrEntryPinned   = errors.New("cache entry has active leases")
+)
+
+// Lease pins a cache entry so it is neither evicted nor removed while a
+// worktree of the repository is in use. Leases are held in memory only.
+type Lease struct {
+	Key        string
+	Repository *models.RepositoryConcreate
+	AcquiredAt time.Time
+	// ExpiresAt is zero for leases without a TTL.
+	ExpiresAt time.Time
+	id        uint64
+	cache     *LRUCache
+}
+
+func (l *Lease) expired(now time.Time) bool {
+	return !l.ExpiresAt.IsZero() && now.After(l.ExpiresAt)
+}
+
+// Release returns the lease. Releasing twice is a no-op.
+func (l *Lease) Release() {
+	lock.Lock()
+	defer lock.Unlock()
+	l.cache.dropLease(l.Key, l.id)
+}
+
+// Renew extends a lease by ttl from now. It fails if the lease was released
+// or force-expired in the meantime.
+func (l *Lease) Renew(ttl time.Duration) error {
+	lock.Lock()
+	defer lock.Unlock()
+	if _, found := l.cache.leases[l.Key][l.id]; !found {
+		return ErrEntryNotFound
+	}
+	l.ExpiresAt = leaseExpiry(time.Now(), ttl)
+	return nil
+}
+
+func leaseExpiry(now time.Time, ttl time.Duration) time.Time {
+	if ttl <= 0 {
+		return time.Time{}
+	}
+	return now.Add(ttl)
+}
+
+// Acquire marks key as most recently used and pins it until the returned lease
+// is released or, when ttl is positive, until ttl has passed.
+func (c *LRUCache) Acquire(key string, ttl time.Duration) (*Lease, error) {
+	lock.Lock()
+	defer lock.Unlock()
+	node, found := c.Mapcache[key]
+	if !found || node == nil {
+		return nil, ErrEntryNotFound
+	}
+	repository := node.Val
+	c.removeNode(key)
+	c.addHead(key, repository)
+	if c.leases == nil {
+		c.leases = map[string]map[uint64]*Lease{}
+	}
+	if c.leases[key] == nil {
+		c.leases[key] = map[uint64]*Lease{}
+	}
+	c.leaseSeq++
+	now := time.Now()
+	lease := &Lease{
+		Key:        key,
+		Repository: repository,
+		AcquiredAt: now,
+		ExpiresAt:  leaseExpiry(now, ttl),
+		id:         c.leaseSeq,
+		cache:      c,
+	}
+	c.leases[key][lease.id] = lease
+	c.Sync(c.Path)
+	return lease, nil
+}
+
+// IsPinned reports whether key has at least one unexpired lease.
+func (c *LRUCache) IsPinned(key string) bool {
+	lock.Lock()
+	defer lock.Unlock()
+	return c.isPinned(key, time.Now())
+}
+
+// isPinned drops the expired leases of key, which were never released, and
+// reports whether any is left.
+func (c *LRUCache) isPinned(key string, now time.Time) bool {
+	pinned := false
+	for id, lease := range c.leases[key] {
+		if lease.expired(now) {
+			c.dropLease(key, id)
+			continue
+		}
+		pinned = true
+	}
+	return pinned
+}
+
+// WaitUnpinned blocks until key has no unexpired leases or ctx is done.
+func (c *LRUCache) WaitUnpinned(ctx context.Context, key string) error {
+	for {
+		lock.Lock()
+		now := time.Now()
+		if !c.isPinned(key, now) {
+			lock.Unlock()
+			return nil
+		}
+		changed := c.leaseChangedChan()
+		wait := c.nextLeaseExpiry(key, now)
+		lock.Unlock()
+
+		var timer *time.Timer
+		var timeout <-chan time.Time
+		if wait > 0 {
+			timer = time.NewTimer(wait)
+			timeout = timer.C
+		}
+		select {
+		case <-ctx.Done():
+			err := ctx.Err()
+			if timer != nil {
+				timer.Stop()
+			}
+			return err
+		case <-changed:
+		case <-timeout:
+		}
+		if timer != nil {
+			timer.Stop()
+		}
+	}
+}
+
+// ExpireLeases force-expires leases older than maxAge, e.g. those left behind
+// by crashed workers, and returns how many were dropped. An empty key applies
+// to every entry.
+func (c *LRUCache) ExpireLeases(key string, maxAge time.Duration) int {
+	lock.Lock()
+	defer lock.Unlock()
+	now := time.Now()
+	expired := 0
+	for leaseKey, leases := range c.leases {
+		if key != "" && leaseKey != key {
+			continue
+		}
+		for id, lease := range leases {
+			if lease.expired(now) || now.Sub(lease.AcquiredAt) >= maxAge {
+				c.dropLease(leaseKey, id)
+				expired++
+			}
+		}
+	}
+	return expired
+}
+
+func (c *LRUCache) dropLease(key string, id uint64) {
+	leases, found := c.leases[key]
+	if !found {
+		return
+	}
+	if _, found := leases[id]; !found {
+		return
+	}
+	delete(leases, id)
+	if len(leases) == 0 {
+		delete(c.leases, key)
+	}
+	if c.leaseChanged != nil {
+		close(c.leaseChanged)
+		c.leaseChanged = nil
+	}
+}
+
+func (c *LRUCache) leaseChangedChan() chan struct{} {
+	if c.leaseChanged == nil {
+		c.leaseChanged = make(chan struct{})
+	}
+	return c.leaseChanged
+}
+
+func (c *LRUCache) nextLeaseExpiry(key string, now time.Time) time.Duration {
+	var next time.Duration
+	for _, lease := range c.leases[key] {
+		if lease.ExpiresAt.IsZero() || !lease.ExpiresAt.After(now) {
+			continue
+		}
+		if wait := lease.ExpiresAt.Sub(now); next == 0 || wait < next {
+			next = wait
+		}
+	}
+	return next
+}
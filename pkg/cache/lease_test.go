@@ -0,0 +1,131 @@
+package cache
+
+import (
+	"context"
+	"operarius/internal/models"
+	"operarius/pkg/git_wrapper"
+	mock_git_wrapper "operarius/pkg/git_wrapper/mock"
+	"path/filepath"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Lease unit test", func() {
+	var mockCtrl *gomock.Controller
+	var cache *LRUCache
+	var sizes map[git_wrapper.IRepository]uint64
+	BeforeEach(func() {
+		mockCtrl = gomock.NewController(GinkgoT())
+		cache = NewLRUCache(100)
+		cache.Path = filepath.Join(GinkgoT().TempDir(), "cache_metadata.json")
+		sizes = stubRepositorySize()
+	})
+
+	put := func(key string, size uint64) *mock_git_wrapper.MockIRepository {
+		repository := mock_git_wrapper.NewMockIRepository(mockCtrl)
+		sizes[repository] = size
+		cache.Put(key, &models.RepositoryConcreate{Size: size, RootRepository: repository})
+		return repository
+	}
+
+	Context("Acquire(key string, ttl time.Duration) (*Lease, error)", func() {
+		It("Should return ErrEntryNotFound for unknown keys", func() {
+			_, err := cache.Acquire("missing", 0)
+			Expect(err).To(Equal(ErrEntryNotFound))
+		})
+
+		It("Should move the entry to the head and pin it", func() {
+			put("a", 10)
+			put("b", 10)
+			lease, err := cache.Acquire("a", 0)
+			Expect(err).To(BeNil())
+			Expect(cache.Head.Key).To(Equal("a"))
+			Expect(cache.IsPinned("a")).To(BeTrue())
+			lease.Release()
+			lease.Release()
+			Expect(cache.IsPinned("a")).To(BeFalse())
+		})
+
+		It("Should stop pinning once the ttl has passed", func() {
+			put("a", 10)
+			_, err := cache.Acquire("a", time.Millisecond)
+			Expect(err).To(BeNil())
+			Eventually(func() bool { return cache.IsPinned("a") }).Should(BeFalse())
+		})
+	})
+
+	Context("Eviction", func() {
+		It("Should skip pinned entries and evict the next least recently used one", func() {
+			put("a", 40)
+			repositoryB := put("b", 40)
+			lease, _ := cache.Acquire("a", 0)
+			put("c", 10)
+			repositoryB.EXPECT().RemoveRepository().Return(nil).Times(1)
+			put("d", 30)
+			Expect(keys(cache)).To(Equal([]string{"d", "c", "a"}))
+			lease.Release()
+		})
+	})
+
+	Context("RemoveNodeLock(key string) error", func() {
+		It("Should refuse to remove a pinned entry", func() {
+			repository := put("a", 10)
+			lease, _ := cache.Acquire("a", 0)
+			Expect(cache.RemoveNodeLock("a")).To(Equal(ErrEntryPinned))
+			lease.Release()
+			repository.EXPECT().RemoveRepository().Return(nil).Times(1)
+			Expect(cache.RemoveNodeLock("a")).To(BeNil())
+			Expect(cache.Mapcache).NotTo(HaveKey("a"))
+		})
+	})
+
+	Context("WaitUnpinned(ctx context.Context, key string) error", func() {
+		It("Should return once every lease is released", func() {
+			put("a", 10)
+			lease, _ := cache.Acquire("a", 0)
+			go func() {
+				time.Sleep(10 * time.Millisecond)
+				lease.Release()
+			}()
+			ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+			defer cancel()
+			Expect(cache.WaitUnpinned(ctx, "a")).To(Succeed())
+		})
+
+		It("Should return once the last ttl lease expires, ignoring expired ones", func() {
+			put("a", 10)
+			_, _ = cache.Acquire("a", time.Millisecond)
+			time.Sleep(5 * time.Millisecond)
+			_, _ = cache.Acquire("a", 20*time.Millisecond)
+			ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+			defer cancel()
+			Expect(cache.WaitUnpinned(ctx, "a")).To(Succeed())
+			Expect(cache.leases).NotTo(HaveKey("a"))
+		})
+
+		It("Should return the context error if the lease is still held", func() {
+			put("a", 10)
+			_, _ = cache.Acquire("a", 0)
+			ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+			defer cancel()
+			Expect(cache.WaitUnpinned(ctx, "a")).To(Equal(context.DeadlineExceeded))
+		})
+	})
+
+	Context("ExpireLeases(key string, maxAge time.Duration) int", func() {
+		It("Should force-expire stale leases", func() {
+			put("a", 10)
+			put("b", 10)
+			stale, _ := cache.Acquire("a", 0)
+			stale.AcquiredAt = time.Now().Add(-time.Hour)
+			_, _ = cache.Acquire("b", 0)
+			Expect(cache.ExpireLeases("", time.Minute)).To(Equal(1))
+			Expect(cache.IsPinned("a")).To(BeFalse())
+			Expect(cache.IsPinned("b")).To(BeTrue())
+			Expect(stale.Renew(time.Minute)).To(Equal(ErrEntryNotFound))
+		})
+	})
+})
@@ -0,0 +1,81 @@
+package cache
+
+import (
+	"context"
+	"io/fs"
+	"operarius/pkg/git_wrapper"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+var repositorySizeFunc = RepositorySize
+
+// RepositorySize returns the on-disk footprint of a repository in KB: its
+// object store plus the checked out files of every worktree.
+func RepositorySize(ctx context.Context, repository git_wrapper.IRepository) (uint64, error) {
+	count, err := repository.CountObjectsContext(ctx)
+	if err != nil {
+		return 0, err
+	}
+	size := count.TotalSize()
+	// parents sort before the worktrees nested in them, which their walk
+	// already counted
+	paths := []string{}
+	for _, worktree := range repository.GetWorktrees() {
+		paths = append(paths, filepath.Clean(worktree.Path))
+	}
+	sort.Strings(paths)
+	measured := []string{}
+	for _, path := range paths {
+		if err := ctx.Err(); err != nil {
+			return 0, err
+		}
+		if isUnderAny(path, measured) {
+			continue
+		}
+		worktreeSize, err := dirSize(path)
+		if err != nil {
+			continue
+		}
+		measured = append(measured, path)
+		size += worktreeSize
+	}
+	return size, nil
+}
+
+func isUnderAny(path string, parents []string) bool {
+	for _, parent := range parents {
+		if path == parent || strings.HasPrefix(path, parent+string(filepath.Separator)) {
+			return true
+		}
+	}
+	return false
+}
+
+// dirSize sums regular file sizes under path in KB. The .git directory is
+// skipped since the object store is accounted for by count-objects.
+func dirSize(path string) (uint64, error) {
+	var bytes uint64
+	err := filepath.WalkDir(path, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			if p == path {
+				return err
+			}
+			return nil
+		}
+		if d.IsDir() && d.Name() == ".git" {
+			return filepath.SkipDir
+		}
+		if !d.Type().IsRegular() {
+			return nil
+		}
+		info, err := d.Info()
+		if err != nil {
+			return nil
+		}
+		bytes += uint64(info.Size())
+		return nil
+	})
+	return (bytes + 1023) / 1024, err
+}
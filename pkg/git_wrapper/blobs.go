@@ -0,0 +1,128 @@
+package git_wrapper
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"strings"
+)
+
+// binaryProbeSize is how much of a blob git itself inspects for a NUL byte to
+// decide it is binary.
+const binaryProbeSize = 8000
+
+type HistoryBlob struct {
+	ID string
+	// Path and CommitSHA are where the blob first appears, walking from the
+	// oldest commit.
+	Path      string
+	CommitSHA string
+	Size      int64
+	Content   []byte
+}
+
+type BlobIteratorOptions struct {
+	// Revisions are passed to rev-list, e.g. "main" or "^base" "head". All
+	// refs are walked when empty.
+	Revisions []string
+	// MaxSize skips blobs larger than the given number of bytes when positive.
+	MaxSize int64
+	// SkipBinary skips blobs with a NUL byte in their first 8000 bytes.
+	SkipBinary bool
+}
+
+func (r *Repository) ForEachBlob(option *BlobIteratorOptions, fn func(HistoryBlob) error) error {
+	return r.ForEachBlobContext(context.Background(), option, fn)
+}
+
+// ForEachBlobContext calls fn once for every unique blob reachable from the
+// revisions. Iteration stops at the first error returned by fn.
+func (r *Repository) ForEachBlobContext(ctx context.Context, option *BlobIteratorOptions, fn func(HistoryBlob) error) error {
+	if option == nil {
+		option = &BlobIteratorOptions{}
+	}
+	revisions := option.Revisions
+	if len(revisions) == 0 {
+		revisions = []string{"--all"}
+	} else {
+		for _, revision := range revisions {
+			if strings.HasPrefix(revision, "-") {
+				return fmt.Errorf("invalid revision %q", revision)
+			}
+		}
+	}
+	// --in-commit-order prints the objects of each commit right after it, and
+	// --reverse walks from the oldest commit so objects are attributed to the
+	// commit that introduced them.
+	args := append([]string{"rev-list", "--objects", "--in-commit-order", "--reverse"}, revisions...)
+	process, err := startGitProcess(r.Dest, append(args, "--"))
+	if err != nil {
+		return err
+	}
+	process.stdin.Close()
+	stop := context.AfterFunc(ctx, process.kill)
+	defer stop()
+
+	iterErr := r.iterateRevList(ctx, process, option, fn)
+	if iterErr != nil {
+		process.kill()
+	}
+	waitErr := process.cmd.Wait()
+	process.cancel()
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+	if iterErr != nil {
+		return iterErr
+	}
+	if waitErr != nil {
+		return newGitError("git", args, waitErr, process.stderr.String())
+	}
+	return nil
+}
+
+func (r *Repository) iterateRevList(ctx context.Context, process *gitProcess, option *BlobIteratorOptions, fn func(HistoryBlob) error) error {
+	objects := r.objectReader()
+	commitSHA := ""
+	for {
+		line, err := process.stdout.ReadString('\n')
+		if line == "" && err != nil {
+			// EOF, or the process died and Wait reports why
+			return nil
+		}
+		line = strings.TrimSuffix(line, "\n")
+		id, path, hasPath := strings.Cut(line, " ")
+		if !hasPath {
+			commitSHA = id
+			continue
+		}
+		if path == "" {
+			// root tree
+			continue
+		}
+		info, err := objects.Info(ctx, id)
+		if err != nil {
+			return err
+		}
+		if info.Type != "blob" || (option.MaxSize > 0 && info.Size > option.MaxSize) {
+			continue
+		}
+		_, content, err := objects.Read(ctx, id)
+		if err != nil {
+			return err
+		}
+		if option.SkipBinary && isBinary(content) {
+			continue
+		}
+		if err := fn(HistoryBlob{ID: id, Path: path, CommitSHA: commitSHA, Size: info.Size, Content: content}); err != nil {
+			return err
+		}
+	}
+}
+
+func isBinary(content []byte) bool {
+	if len(content) > binaryProbeSize {
+		content = content[:binaryProbeSize]
+	}
+	return bytes.IndexByte(content, 0) >= 0
+}
@@ -0,0 +1,87 @@
+package git_wrapper
+
+import (
+	"context"
+	"errors"
+	"strings"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Blobs unit test", func() {
+	Context("ForEachBlob(option *BlobIteratorOptions, fn func(HistoryBlob) error) error", func() {
+		var repository *Repository
+		var first, second, third string
+		BeforeEach(func() {
+			dest := initTestRepository(map[string]string{"a.txt": "secret=1\n"})
+			first = runTestGit(dest, "rev-parse", "HEAD")
+			a, copied, bin, big := "secret=2\n", "secret=1\n", "\x00\x01", strings.Repeat("x", 100)
+			second = commitTestFiles(dest, map[string]*string{"a.txt": &a, "dir/copy.txt": &copied, "bin": &bin, "big": &big})
+			third = commitTestFiles(dest, map[string]*string{"a.txt": nil})
+			repository = NewRepository("", dest)
+			DeferCleanup(repository.Close)
+		})
+
+		collect := func(option *BlobIteratorOptions) map[string]HistoryBlob {
+			blobs := map[string]HistoryBlob{}
+			err := repository.ForEachBlob(option, func(blob HistoryBlob) error {
+				Expect(blobs).ShouldNot(HaveKey(string(blob.Content)))
+				blobs[string(blob.Content)] = blob
+				return nil
+			})
+			Expect(err).Should(BeNil())
+			return blobs
+		}
+
+		It("Should yield every unique blob once with the commit introducing it", func() {
+			blobs := collect(nil)
+			Expect(blobs).Should(HaveLen(4))
+			Expect(blobs["secret=1\n"].Path).Should(Equal("a.txt"))
+			Expect(blobs["secret=1\n"].CommitSHA).Should(Equal(first))
+			Expect(blobs["secret=1\n"].Size).Should(Equal(int64(9)))
+			Expect(blobs["secret=2\n"].Path).Should(Equal("a.txt"))
+			Expect(blobs["secret=2\n"].CommitSHA).Should(Equal(second))
+			Expect(blobs).Should(HaveKey("\x00\x01"))
+			Expect(blobs).Should(HaveKey(strings.Repeat("x", 100)))
+		})
+
+		It("Should skip binary and large blobs", func() {
+			blobs := collect(&BlobIteratorOptions{MaxSize: 50, SkipBinary: true})
+			Expect(blobs).Should(HaveLen(2))
+			Expect(blobs).Should(HaveKey("secret=1\n"))
+			Expect(blobs).Should(HaveKey("secret=2\n"))
+		})
+
+		It("Should limit the walk to a revision range", func() {
+			blobs := collect(&BlobIteratorOptions{Revisions: []string{"^" + first, third}})
+			Expect(blobs).Should(HaveLen(3))
+			Expect(blobs).ShouldNot(HaveKey("secret=1\n"))
+		})
+
+		It("Should stop on the first callback error", func() {
+			stop := errors.New("stop")
+			calls := 0
+			err := repository.ForEachBlob(nil, func(blob HistoryBlob) error {
+				calls++
+				return stop
+			})
+			Expect(err).Should(MatchError(stop))
+			Expect(calls).Should(Equal(1))
+		})
+
+		It("Should stop on a cancelled context", func() {
+			ctx, cancel := context.WithCancel(context.Background())
+			err := repository.ForEachBlobContext(ctx, nil, func(blob HistoryBlob) error {
+				cancel()
+				return nil
+			})
+			Expect(err).Should(MatchError(context.Canceled))
+		})
+
+		It("Should report an unknown revision", func() {
+			err := repository.ForEachBlob(&BlobIteratorOptions{Revisions: []string{"does-not-exist"}}, func(HistoryBlob) error { return nil })
+			Expect(err).Should(MatchError(ErrBadRef))
+		})
+	})
+})
@@ -9,3 +9,7 @@ func NewBranch(name string) Branch {
 		name: name,
 	}
 }
+
+func (b Branch) Name() string {
+	return b.name
+}
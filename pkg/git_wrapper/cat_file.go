@@ -0,0 +1,206 @@
+package git_wrapper
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os/exec"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+var ErrObjectNotFound = errors.New("git: object not found")
+
+type ObjectInfo struct {
+	ID   string
+	Type string
+	Size int64
+}
+
+// gitProcess is a running git command whose stdin and stdout are piped, such
+// as `git cat-file --batch` where requests are written one per line on stdin
+// and answered in order on stdout.
+type gitProcess struct {
+	cmd    *exec.Cmd
+	cancel context.CancelFunc
+	stdin  io.WriteCloser
+	stdout *bufio.Reader
+	stderr *bytes.Buffer
+}
+
+func startGitProcess(dest string, args []string) (*gitProcess, error) {
+	log.Printf("Exec at %s: Command = git, Arguments = %v", dest, redactArgs(args, nil))
+	// The process outlives any single request, which cancel it through kill.
+	ctx, cancel := context.WithCancel(context.Background())
+	cmd := exec.CommandContext(ctx, "git", args...)
+	cmd.Dir = dest
+	cmd.Env = (&CommandBuilder{isolated: true}).buildEnv()
+	setProcessGroup(cmd)
+	stderr := &bytes.Buffer{}
+	cmd.Stderr = stderr
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+	if err := cmd.Start(); err != nil {
+		cancel()
+		return nil, err
+	}
+	return &gitProcess{
+		cmd:    cmd,
+		cancel: cancel,
+		stdin:  stdin,
+		stdout: bufio.NewReader(stdout),
+		stderr: stderr,
+	}, nil
+}
+
+// close ends the process once it has answered every pending request.
+func (p *gitProcess) close() error {
+	defer p.cancel()
+	p.stdin.Close()
+	return p.cmd.Wait()
+}
+
+func (p *gitProcess) kill() {
+	p.cancel()
+}
+
+// request asks cat-file for one object and parses the header of the answer. The
+// content, if any, is left on stdout for the caller.
+func (p *gitProcess) request(name string) (ObjectInfo, error) {
+	if _, err := io.WriteString(p.stdin, name+"\n"); err != nil {
+		return ObjectInfo{}, err
+	}
+	header, err := p.stdout.ReadString('\n')
+	if err != nil {
+		return ObjectInfo{}, err
+	}
+	return parseCatFileHeader(name, strings.TrimSuffix(header, "\n"))
+}
+
+// parseCatFileHeader parses "<oid> <type> <size>", or "<name> missing" and
+// "<name> ambiguous" for objects that can't be resolved.
+func parseCatFileHeader(name string, header string) (ObjectInfo, error) {
+	if strings.HasSuffix(header, " missing") || strings.HasSuffix(header, " ambiguous") {
+		return ObjectInfo{}, fmt.Errorf("%w: %s", ErrObjectNotFound, name)
+	}
+	fields := strings.Fields(header)
+	if len(fields) != 3 {
+		return ObjectInfo{}, fmt.Errorf("malformed cat-file header %q", header)
+	}
+	size, err := strconv.ParseInt(fields[2], 10, 64)
+	if err != nil {
+		return ObjectInfo{}, fmt.Errorf("malformed cat-file header %q: %w", header, err)
+	}
+	return ObjectInfo{ID: fields[0], Type: fields[1], Size: size}, nil
+}
+
+// objectReader serves object reads of a repository from long-lived cat-file
+// processes, started on first use and restarted after a failure.
+type objectReader struct {
+	mu    sync.Mutex
+	dest  string
+	batch *gitProcess
+	check *gitProcess
+}
+
+func newObjectReader(dest string) *objectReader {
+	return &objectReader{dest: dest}
+}
+
+// Info resolves name without reading the object content.
+func (o *objectReader) Info(ctx context.Context, name string) (ObjectInfo, error) {
+	var info ObjectInfo
+	err := o.do(ctx, &o.check, "--batch-check", name, func(p *gitProcess) error {
+		var err error
+		info, err = p.request(name)
+		return err
+	})
+	return info, err
+}
+
+// Read returns the object content of name.
+func (o *objectReader) Read(ctx context.Context, name string) (ObjectInfo, []byte, error) {
+	var info ObjectInfo
+	var content []byte
+	err := o.do(ctx, &o.batch, "--batch", name, func(p *gitProcess) error {
+		var err error
+		info, err = p.request(name)
+		if err != nil {
+			return err
+		}
+		// the content is followed by a newline
+		content = make([]byte, info.Size+1)
+		if _, err := io.ReadFull(p.stdout, content); err != nil {
+			return err
+		}
+		content = content[:info.Size]
+		return nil
+	})
+	return info, content, err
+}
+
+func (o *objectReader) do(ctx context.Context, slot **gitProcess, mode string, name string, fn func(*gitProcess) error) error {
+	if strings.ContainsAny(name, "\n\x00") {
+		return fmt.Errorf("invalid object name %q", name)
+	}
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	if *slot == nil {
+		p, err := startGitProcess(o.dest, []string{"cat-file", mode})
+		if err != nil {
+			return err
+		}
+		*slot = p
+	}
+	p := *slot
+	stop := context.AfterFunc(ctx, p.kill)
+	err := fn(p)
+	if !stop() {
+		// the process was killed half way through, start a new one next time
+		p.close()
+		*slot = nil
+		return ctx.Err()
+	}
+	if err != nil && !errors.Is(err, ErrObjectNotFound) {
+		p.kill()
+		p.close()
+		*slot = nil
+		if stderr := strings.TrimSpace(p.stderr.String()); stderr != "" {
+			return newGitError("git", []string{"cat-file", mode}, err, stderr)
+		}
+	}
+	return err
+}
+
+// Close stops the cat-file processes.
+func (o *objectReader) Close() error {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	var err error
+	for _, slot := range []**gitProcess{&o.batch, &o.check} {
+		if *slot == nil {
+			continue
+		}
+		if closeErr := (*slot).close(); closeErr != nil && err == nil {
+			err = closeErr
+		}
+		*slot = nil
+	}
+	return err
+}
@@ -3,10 +3,11 @@ package git_wrapper
 import (
 	"bufio"
 	"bytes"
-	"errors"
+	"context"
 	"fmt"
+	"io"
 	"log"
-	"operarius/internal/utils"
+	"os"
 	"os/exec"
 	"strings"
 
@@ -22,26 +23,43 @@ type CommandBuilder struct {
 	command         string
 	args            []string
 	baseCommandArgs []string
+	env             []string
+	configs         []configEntry
+	secrets         []string
+	isolated        bool
 	logger          logger.ILogger
 }
 
+type configEntry struct {
+	key   string
+	value string
+}
+
 type ICommandBuilder interface {
 	AddCommand(string)
 	AddArg(string)
 	AddBaseCommandArg(string)
 	AddBaseCommandArgs([]string)
 	AddArgs([]string)
+	AddEnv(key string, value string)
+	AddConfig(key string, value string)
+	AddSecret(secret string)
+	SetIsolated(isolated bool)
 	SetDir(dir string)
 	SetLogger(logger.ILogger)
 	Build() string
 	Exec() (string, error)
+	ExecContext(ctx context.Context) (string, error)
 	ExecStreamStdout() (string, error)
+	ExecStreamStdoutContext(ctx context.Context) (string, error)
 	ExecCommandPath(commandPath string, cb func(*exec.Cmd)) error
+	ExecCommandPathContext(ctx context.Context, commandPath string, cb func(*exec.Cmd)) error
 }
 
 func NewCommandBuilder() ICommandBuilder {
 	return &CommandBuilder{
 		baseCommand: "git",
+		isolated:    true,
 	}
 }
 
@@ -69,114 +87,207 @@ func (c *CommandBuilder) AddBaseCommandArgs(args []string) {
 	c.baseCommandArgs = append(c.baseCommandArgs, args...)
 }
 
+// AddEnv sets an environment variable of the command on top of the inherited
+// environment.
+func (c *CommandBuilder) AddEnv(key string, value string) {
+	c.env = append(c.env, key+"="+value)
+}
+
+// AddConfig sets a git config value through the environment rather than with
+// `-c`, so that it never shows up in the argument list.
+func (c *CommandBuilder) AddConfig(key string, value string) {
+	c.configs = append(c.configs, configEntry{key: key, value: value})
+}
+
+// AddSecret registers a value masked wherever the command is shown: logs,
+// errors and Build. URL credentials and authorization headers are always
+// masked.
+func (c *CommandBuilder) AddSecret(secret string) {
+	if secret != "" {
+		c.secrets = append(c.secrets, secret)
+	}
+}
+
+// SetIsolated runs git with the host config and environment, and without the
+// hardening of isolationConfigs, when false. Commands are isolated by default
+// since they run on repositories that aren't trusted.
+func (c *CommandBuilder) SetIsolated(isolated bool) {
+	c.isolated = isolated
+}
+
+// buildEnv returns the environment of the command. Messages are always in
+// English since errors are classified by matching git's output.
+func (c *CommandBuilder) buildEnv() []string {
+	env := os.Environ()
+	configs := c.configs
+	if c.isolated {
+		env = isolatedEnv()
+		// added configs come last to take precedence
+		configs = append(append([]configEntry{}, isolationConfigs...), c.configs...)
+	}
+	env = append(env, "LC_ALL=C", "LANGUAGE=C")
+	env = append(env, c.env...)
+	if len(configs) > 0 {
+		env = append(env, fmt.Sprintf("GIT_CONFIG_COUNT=%d", len(configs)))
+		for i, config := range configs {
+			env = append(env, fmt.Sprintf("GIT_CONFIG_KEY_%d=%s", i, config.key), fmt.Sprintf("GIT_CONFIG_VALUE_%d=%s", i, config.value))
+		}
+	}
+	return env
+}
+
 func (c *CommandBuilder) SetDir(dir string) {
 	c.dir = dir
 }
 
 func (c *CommandBuilder) Build() string {
 	args := strings.Trim(fmt.Sprintf("%s %s", c.command, strings.Join(c.args, " ")), " ")
-	return fmt.Sprintf("%s %s", c.baseCommand, args)
+	return redact(fmt.Sprintf("%s %s", c.baseCommand, args), c.secrets)
 }
 
-func (c *CommandBuilder) Exec() (string, error) {
-	var errb bytes.Buffer
-	args := c.baseCommandArgs
+func (c *CommandBuilder) buildArgs() []string {
+	args := []string{}
+	args = append(args, c.baseCommandArgs...)
 	args = append(args, c.command)
 	args = append(args, c.args...)
+	return args
+}
+
+func (c *CommandBuilder) logExec(args []string) {
+	args = redactArgs(args, c.secrets)
 	if c.logger != nil {
 		c.logger.Debugf("Exec at %s: Command = %s, Arguments = %v", c.dir, c.baseCommand, args)
 	} else {
 		log.Printf("Exec at %s: Command = %s, Arguments = %v", c.dir, c.baseCommand, args)
 	}
-	cmd := exec.Command(c.baseCommand, args...)
+}
+
+func (c *CommandBuilder) Exec() (string, error) {
+	return c.ExecContext(context.Background())
+}
+
+func (c *CommandBuilder) ExecContext(ctx context.Context) (string, error) {
+	var errb bytes.Buffer
+	args := c.buildArgs()
+	c.logExec(args)
+	cmd := exec.CommandContext(ctx, c.baseCommand, args...)
+	setProcessGroup(cmd)
+	cmd.Env = c.buildEnv()
 	cmd.Stderr = &errb
 	if c.dir != "" {
 		cmd.Dir = c.dir
 	}
 	stdout, err := cmd.Output()
+	var gitErr *GitError
+	if err != nil {
+		gitErr = c.newGitError(c.baseCommand, args, err, errb.String())
+	}
 	c.Reset()
 	if err != nil {
-		log.Println("err: ", strings.TrimSpace(errb.String()))
-		return "", errors.New(strings.TrimSpace(errb.String()))
+		if ctx.Err() != nil {
+			return "", ctx.Err()
+		}
+		log.Println("err: ", gitErr.Stderr)
+		return "", gitErr
 	}
-	cmd.Wait()
 	return string(stdout), nil
 }
 
+// newGitError builds the error of a failed run with the registered secrets
+// masked in the command and in git's output.
+func (c *CommandBuilder) newGitError(baseCommand string, args []string, err error, stderr string) *GitError {
+	return newGitError(baseCommand, redactArgs(args, c.secrets), err, redact(stderr, c.secrets))
+}
+
 func (c *CommandBuilder) Reset() {
 	c.baseCommand = ""
 	c.dir = ""
 	c.command = ""
 	c.args = []string{}
+	c.env = nil
+	c.configs = nil
+	c.secrets = nil
 }
 
 func (c *CommandBuilder) ExecStreamStdout() (string, error) {
-	args := []string{c.command}
-	args = append(args, c.baseCommandArgs...)
-	args = append(args, c.args...)
-	cmd := exec.Command(c.baseCommand, args...)
-	if c.logger != nil {
-		c.logger.Debugf("Exec at %s: Command = %s, Arguments = %v", c.dir, c.baseCommand, args)
-	} else {
-		log.Printf("Exec at %s: Command = %s, Arguments = %v", c.dir, c.baseCommand, args)
-	}
+	return c.ExecStreamStdoutContext(context.Background())
+}
+
+func (c *CommandBuilder) ExecStreamStdoutContext(ctx context.Context) (string, error) {
+	var stdout bytes.Buffer
+	var errb bytes.Buffer
+	args := c.buildArgs()
+	cmd := exec.CommandContext(ctx, c.baseCommand, args...)
+	setProcessGroup(cmd)
+	cmd.Env = c.buildEnv()
+	c.logExec(args)
 	if c.dir != "" {
 		cmd.Dir = c.dir
 	}
-	stderr, _ := cmd.StderrPipe()
-	cmd.Start()
-	scanner := bufio.NewScanner(stderr)
+	cmd.Stdout = &stdout
+	stderr, err := cmd.StderrPipe()
+	if err != nil {
+		c.Reset()
+		return "", err
+	}
+	if err := cmd.Start(); err != nil {
+		c.Reset()
+		return "", err
+	}
+	scanner := bufio.NewScanner(io.TeeReader(stderr, &errb))
 	scanner.Split(bufio.ScanWords)
 	for scanner.Scan() {
 		m := scanner.Text()
-		fmt.Print(m)
+		fmt.Print(redact(m, c.secrets))
+	}
+	err = cmd.Wait()
+	var gitErr *GitError
+	if err != nil {
+		gitErr = c.newGitError(c.baseCommand, args, err, errb.String())
 	}
-	cmd.Wait()
-	output, err := cmd.Output()
 	c.Reset()
-	return string(output), err
+	if err != nil {
+		if ctx.Err() != nil {
+			return "", ctx.Err()
+		}
+		return "", gitErr
+	}
+	return stdout.String(), nil
 }
 
 func (c *CommandBuilder) ExecCommandPath(commandPath string, cb func(*exec.Cmd)) error {
+	return c.ExecCommandPathContext(context.Background(), commandPath, cb)
+}
+
+func (c *CommandBuilder) ExecCommandPathContext(ctx context.Context, commandPath string, cb func(*exec.Cmd)) error {
 	args := []string{strings.ToLower(c.baseCommand)}
 	args = append(args, c.baseCommandArgs...)
 	args = append(args, strings.ToLower(c.command))
 	args = append(args, c.args...)
 	var stdout bytes.Buffer
 	var stderr bytes.Buffer
-	cmd := &exec.Cmd{
-		Path:   commandPath,
-		Args:   args,
-		Stdout: &stdout,
-		Stderr: &stderr,
-	}
+	cmd := exec.CommandContext(ctx, commandPath)
+	cmd.Args = args
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	cmd.Env = c.buildEnv()
+	setProcessGroup(cmd)
 
 	if cb != nil {
 		cb(cmd)
 	}
-	if c.logger != nil {
-		c.logger.Debugf("Exec at %s: Command = %s, Arguments = %v", c.dir, c.baseCommand, args)
-	} else {
-		log.Printf("Exec at %s: Command = %s, Arguments = %v", c.dir, c.baseCommand, args)
-	}
+	c.logExec(args)
 	err := cmd.Run()
+	var gitErr *GitError
+	if err != nil {
+		gitErr = c.newGitError(args[0], args[1:], err, stderr.String())
+	}
 	c.Reset()
 	if err != nil {
-		if strings.Contains(stderr.String(), "fatal: fetch-pack") ||
-			strings.Contains(stderr.String(), "fatal: early EOF") {
-			return utils.ErrCloneFailedDueToLackofMemory
-		} else {
-			errMsg := stderr.String()
-			p := strings.Split(errMsg, "\n")
-			if len(p) >= 2 {
-				errMsg = p[1]
-				errMsg = strings.TrimSpace(errMsg)
-				if errMsg == "" {
-					errMsg = stderr.String()
-				}
-			}
-			return fmt.Errorf("error: %s", errMsg)
+		if ctx.Err() != nil {
+			return ctx.Err()
 		}
+		return gitErr
 	}
 	return nil
 }
@@ -1,7 +1,9 @@
 package git_wrapper
 
 import (
+	"context"
 	"fmt"
+	"strconv"
 	"strings"
 
 	"github.com/samber/lo"
@@ -20,6 +22,10 @@ func NewCommit(commitHash string, dest string) Commit {
 }
 
 func (c Commit) DiffListFileChanged(targetCommit *Commit) ([]string, error) {
+	return c.DiffListFileChangedContext(context.Background(), targetCommit)
+}
+
+func (c Commit) DiffListFileChangedContext(ctx context.Context, targetCommit *Commit) ([]string, error) {
 	commandBuilder := commandBuilderFunc()
 	commandBuilder.SetDir(c.dest)
 	commandBuilder.AddCommand("diff")
@@ -29,7 +35,7 @@ func (c Commit) DiffListFileChanged(targetCommit *Commit) ([]string, error) {
 		commandBuilder.AddArg(targetCommit.hash)
 	}
 	commandBuilder.AddArg(c.hash)
-	output, err := commandBuilder.Exec()
+	output, err := commandBuilder.ExecContext(ctx)
 	formatedOutput := strings.Split(output, "\n")
 	formatedOutput = lo.FilterMap(formatedOutput, func(s string, i int) (string, bool) {
 		formated := strings.Trim(strings.Trim(s, " "), "* ")
@@ -41,13 +47,350 @@ func (c Commit) DiffListFileChanged(targetCommit *Commit) ([]string, error) {
 	return formatedOutput, err
 }
 
-func (c Commit) DiffShortStat(targetCommit *Commit) {
+type DiffStat struct {
+	FilesChanged int
+	Insertions   int
+	Deletions    int
+}
+
+// DiffShortStat summarises the changes between targetCommit and c. Without
+// targetCommit, the working tree is compared against c, as in
+// DiffListFileChanged.
+func (c Commit) DiffShortStat(targetCommit *Commit) (DiffStat, error) {
+	return c.DiffShortStatContext(context.Background(), targetCommit)
+}
+
+func (c Commit) DiffShortStatContext(ctx context.Context, targetCommit *Commit) (DiffStat, error) {
 	commandBuilder := commandBuilderFunc()
+	commandBuilder.SetDir(c.dest)
+	c.addDiffRevisions(commandBuilder, targetCommit, []string{"--shortstat", "--find-renames"}, nil)
+	output, err := commandBuilder.ExecContext(ctx)
+	if err != nil {
+		return DiffStat{}, err
+	}
+	return ParseShortStat(output)
+}
+
+// ParseShortStat parses a line such as
+// " 5 files changed, 54 insertions(+), 2 deletions(-)". Git leaves out the
+// parts that are zero and prints nothing at all for an empty diff.
+func ParseShortStat(output string) (DiffStat, error) {
+	stat := DiffStat{}
+	output = strings.TrimSpace(output)
+	if output == "" {
+		return stat, nil
+	}
+	for _, part := range strings.Split(output, ",") {
+		value, label, _ := strings.Cut(strings.TrimSpace(part), " ")
+		count, err := strconv.Atoi(value)
+		if err != nil {
+			return stat, fmt.Errorf("malformed shortstat %q: %w", output, err)
+		}
+		switch {
+		case strings.HasPrefix(label, "file"):
+			stat.FilesChanged = count
+		case strings.HasPrefix(label, "insertion"):
+			stat.Insertions = count
+		case strings.HasPrefix(label, "deletion"):
+			stat.Deletions = count
+		default:
+			return stat, fmt.Errorf("malformed shortstat %q", output)
+		}
+	}
+	return stat, nil
+}
+
+type FileNumStat struct {
+	// OldPath differs from NewPath only for renames and copies.
+	OldPath  string
+	NewPath  string
+	Added    int
+	Deleted  int
+	IsBinary bool
+}
+
+// DiffNumStat returns the number of added and deleted lines per file between
+// targetCommit and c. Binary files are reported with IsBinary and no counts.
+func (c Commit) DiffNumStat(targetCommit *Commit, option *DiffOptions) ([]FileNumStat, error) {
+	return c.DiffNumStatContext(context.Background(), targetCommit, option)
+}
+
+func (c Commit) DiffNumStatContext(ctx context.Context, targetCommit *Commit, option *DiffOptions) ([]FileNumStat, error) {
+	commandBuilder := commandBuilderFunc()
+	commandBuilder.SetDir(c.dest)
+	args := append([]string{"--numstat", "-z"}, option.args()...)
+	c.addDiffRevisions(commandBuilder, targetCommit, args, option)
+	output, err := commandBuilder.ExecContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return ParseNumStat(output)
+}
+
+// ParseNumStat parses `git diff --numstat -z` output. Each entry is
+// "added\tdeleted\tpath", except renames and copies which leave the path empty
+// and follow it with the source and destination paths.
+func ParseNumStat(output string) ([]FileNumStat, error) {
+	stats := []FileNumStat{}
+	fields := strings.Split(strings.TrimSuffix(output, "\x00"), "\x00")
+	for i := 0; i < len(fields); i++ {
+		entry := fields[i]
+		if entry == "" {
+			continue
+		}
+		parts := strings.SplitN(entry, "\t", 3)
+		if len(parts) != 3 {
+			return nil, fmt.Errorf("malformed numstat entry %q", entry)
+		}
+		stat := FileNumStat{}
+		if parts[0] == "-" && parts[1] == "-" {
+			stat.IsBinary = true
+		} else {
+			var err error
+			if stat.Added, err = strconv.Atoi(parts[0]); err != nil {
+				return nil, fmt.Errorf("malformed numstat entry %q: %w", entry, err)
+			}
+			if stat.Deleted, err = strconv.Atoi(parts[1]); err != nil {
+				return nil, fmt.Errorf("malformed numstat entry %q: %w", entry, err)
+			}
+		}
+		if parts[2] != "" {
+			stat.OldPath, stat.NewPath = parts[2], parts[2]
+		} else {
+			if i+2 >= len(fields) {
+				return nil, fmt.Errorf("missing paths for numstat entry %q", entry)
+			}
+			stat.OldPath, stat.NewPath = fields[i+1], fields[i+2]
+			i += 2
+		}
+		stats = append(stats, stat)
+	}
+	return stats, nil
+}
+
+type DiffOptions struct {
+	// IgnoreWhitespace drops changes that only touch whitespace (git diff -w).
+	IgnoreWhitespace bool
+	// NoRenames turns rename detection off. Otherwise renames are detected
+	// with RenameThreshold as minimum similarity in percent, 0 meaning git's
+	// default of 50%.
+	NoRenames       bool
+	RenameThreshold int
+	// FindCopies detects copies with CopyThreshold as minimum similarity.
+	FindCopies    bool
+	CopyThreshold int
+	// DiffFilter is passed to --diff-filter, e.g. "ACMR".
+	DiffFilter string
+	// Pathspecs limits the diff to matching paths.
+	Pathspecs []string
+}
+
+func (o *DiffOptions) args() []string {
+	if o == nil {
+		return []string{"--find-renames"}
+	}
+	args := []string{}
+	if o.IgnoreWhitespace {
+		args = append(args, "-w")
+	}
+	switch {
+	case o.NoRenames:
+		args = append(args, "--no-renames")
+	case o.RenameThreshold > 0:
+		args = append(args, fmt.Sprintf("--find-renames=%d%%", o.RenameThreshold))
+	default:
+		args = append(args, "--find-renames")
+	}
+	if o.FindCopies {
+		if o.CopyThreshold > 0 {
+			args = append(args, fmt.Sprintf("--find-copies=%d%%", o.CopyThreshold))
+		} else {
+			args = append(args, "--find-copies")
+		}
+	}
+	if o.DiffFilter != "" {
+		args = append(args, "--diff-filter="+o.DiffFilter)
+	}
+	return args
+}
+
+func (o *DiffOptions) pathspecArgs() []string {
+	if o == nil || len(o.Pathspecs) == 0 {
+		return []string{}
+	}
+	return append([]string{"--"}, o.Pathspecs...)
+}
+
+// addDiffRevisions makes commandBuilder diff targetCommit against c, or the
+// working tree against c when there is no target, the same revisions as
+// DiffListFileChanged. Pass the parent of c as target, e.g. c.hash+"^", to
+// get the changes of c alone.
+func (c Commit) addDiffRevisions(commandBuilder ICommandBuilder, targetCommit *Commit, args []string, option *DiffOptions) {
 	commandBuilder.AddCommand("diff")
-	commandBuilder.AddArgs([]string{"--shortstat", c.hash})
 	if targetCommit != nil && targetCommit.hash != "" {
-		commandBuilder.AddArg(targetCommit.hash)
+		args = append(args, targetCommit.hash)
+	}
+	args = append(args, c.hash)
+	commandBuilder.AddArgs(append(args, option.pathspecArgs()...))
+}
+
+// LineRange is an inclusive range of line numbers.
+type LineRange struct {
+	Start int
+	End   int
+}
+
+type FileLineRanges struct {
+	Path   string
+	Ranges []LineRange
+}
+
+// Contains reports whether line falls in one of the ranges.
+func (f FileLineRanges) Contains(line int) bool {
+	for _, r := range f.Ranges {
+		if line >= r.Start && line <= r.End {
+			return true
+		}
+	}
+	return false
+}
+
+// AddedLineRanges returns, for every file changed between targetCommit and c,
+// the ranges of lines added or modified in c. Without targetCommit, the
+// working tree is compared against c. Deleted and binary files are skipped.
+func (c Commit) AddedLineRanges(targetCommit *Commit, option *DiffOptions) ([]FileLineRanges, error) {
+	return c.AddedLineRangesContext(context.Background(), targetCommit, option)
+}
+
+func (c Commit) AddedLineRangesContext(ctx context.Context, targetCommit *Commit, option *DiffOptions) ([]FileLineRanges, error) {
+	commandBuilder := commandBuilderFunc()
+	commandBuilder.SetDir(c.dest)
+	args := patchArgs(append([]string{"--unified=0"}, option.args()...)...)
+	c.addDiffRevisions(commandBuilder, targetCommit, args, option)
+	output, err := commandBuilder.ExecContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+	files, err := ParseUnifiedDiff(output)
+	if err != nil {
+		return nil, err
+	}
+	result := []FileLineRanges{}
+	for _, file := range files {
+		if file.Status == FileStatusDeleted || file.IsBinary {
+			continue
+		}
+		ranges := addedLineRanges(file)
+		if len(ranges) == 0 {
+			continue
+		}
+		result = append(result, FileLineRanges{Path: file.NewPath, Ranges: ranges})
+	}
+	return result, nil
+}
+
+func addedLineRanges(file FileDiff) []LineRange {
+	ranges := []LineRange{}
+	for _, line := range file.AddedLines() {
+		if last := len(ranges) - 1; last >= 0 && ranges[last].End+1 == line.NewLineNumber {
+			ranges[last].End = line.NewLineNumber
+			continue
+		}
+		ranges = append(ranges, LineRange{Start: line.NewLineNumber, End: line.NewLineNumber})
+	}
+	return ranges
+}
+
+type FileChange struct {
+	Status FileStatus
+	// OldPath is empty for added files, NewPath is empty for deleted files.
+	OldPath string
+	NewPath string
+	// Similarity is the rename or copy score in percent.
+	Similarity int
+}
+
+// Path is the path of the file in the new revision, or the old path for
+// deleted files.
+func (f FileChange) Path() string {
+	if f.NewPath != "" {
+		return f.NewPath
+	}
+	return f.OldPath
+}
+
+var fileStatusLetters = map[byte]FileStatus{
+	'A': FileStatusAdded,
+	'C': FileStatusCopied,
+	'M': FileStatusModified,
+	'R': FileStatusRenamed,
+	'D': FileStatusDeleted,
+	'T': FileStatusTypeChanged,
+}
+
+// DiffFileChanges lists every file changed between targetCommit and c with
+// its status, unlike DiffListFileChanged which only returns added, copied,
+// modified and renamed paths. Without targetCommit, the working tree is
+// compared against c, as in DiffListFileChanged.
+func (c Commit) DiffFileChanges(targetCommit *Commit, option *DiffOptions) ([]FileChange, error) {
+	return c.DiffFileChangesContext(context.Background(), targetCommit, option)
+}
+
+func (c Commit) DiffFileChangesContext(ctx context.Context, targetCommit *Commit, option *DiffOptions) ([]FileChange, error) {
+	commandBuilder := commandBuilderFunc()
+	commandBuilder.SetDir(c.dest)
+	args := append([]string{"--name-status", "-z"}, option.args()...)
+	c.addDiffRevisions(commandBuilder, targetCommit, args, option)
+	output, err := commandBuilder.ExecContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return ParseNameStatus(output)
+}
+
+// ParseNameStatus parses `git diff --name-status -z` output, where renames and
+// copies are followed by both the source and the destination path.
+func ParseNameStatus(output string) ([]FileChange, error) {
+	changes := []FileChange{}
+	fields := strings.Split(strings.TrimSuffix(output, "\x00"), "\x00")
+	for i := 0; i < len(fields); i++ {
+		code := strings.TrimSpace(fields[i])
+		if code == "" {
+			continue
+		}
+		status, found := fileStatusLetters[code[0]]
+		if !found {
+			return nil, fmt.Errorf("unknown diff status %q", code)
+		}
+		change := FileChange{Status: status}
+		if len(code) > 1 {
+			similarity, err := strconv.Atoi(code[1:])
+			if err != nil {
+				return nil, fmt.Errorf("invalid diff status %q: %w", code, err)
+			}
+			change.Similarity = similarity
+		}
+		paths := 1
+		if status == FileStatusRenamed || status == FileStatusCopied {
+			paths = 2
+		}
+		if i+paths >= len(fields) {
+			return nil, fmt.Errorf("missing path for diff status %q", code)
+		}
+		switch status {
+		case FileStatusAdded:
+			change.NewPath = fields[i+1]
+		case FileStatusDeleted:
+			change.OldPath = fields[i+1]
+		case FileStatusRenamed, FileStatusCopied:
+			change.OldPath = fields[i+1]
+			change.NewPath = fields[i+2]
+		default:
+			change.OldPath = fields[i+1]
+			change.NewPath = fields[i+1]
+		}
+		changes = append(changes, change)
+		i += paths
 	}
-	output, err := commandBuilder.Exec()
-	fmt.Print(string(output), err)
+	return changes, nil
 }
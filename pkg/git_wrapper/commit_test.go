@@ -2,12 +2,15 @@ package git_wrapper
 
 import (
 	mock_git_wrapper "operarius/mock/pkg/git_wrapper"
+	"os"
+	"path/filepath"
 	"reflect"
 	"testing"
 
 	"github.com/golang/mock/gomock"
 	. "github.com/onsi/ginkgo/v2"
 	. "github.com/onsi/gomega"
+	"github.com/samber/lo"
 )
 
 var _ = Describe("Commit unit test", func() {
@@ -33,7 +36,7 @@ var _ = Describe("Commit unit test", func() {
 			mockCommandBuilder.EXPECT().AddArgs([]string{"--name-only", "--diff-filter=ACMR"})
 			mockCommandBuilder.EXPECT().AddArg("99cdb715ac9cdad0f90f6af6df2757661b117efb")
 			mockCommandBuilder.EXPECT().AddArg("ebc635acded8305a60fec5fad5b66d9d8c74d78f")
-			mockCommandBuilder.EXPECT().Exec().Return("pkg/git_wrapper/git.go", nil).Times(1)
+			mockCommandBuilder.EXPECT().ExecContext(gomock.Any()).Return("pkg/git_wrapper/git.go", nil).Times(1)
 			output, _ := commit.DiffListFileChanged(&targetCommit)
 			Expect(output).To(Equal([]string{"pkg/git_wrapper/git.go"}))
 		})
@@ -44,7 +47,7 @@ var _ = Describe("Commit unit test", func() {
 			mockCommandBuilder.EXPECT().SetDir("/tmp/scan")
 			mockCommandBuilder.EXPECT().AddArgs([]string{"--name-only", "--diff-filter=ACMR"})
 			mockCommandBuilder.EXPECT().AddArg("ebc635acded8305a60fec5fad5b66d9d8c74d78f")
-			mockCommandBuilder.EXPECT().Exec().Return(
+			mockCommandBuilder.EXPECT().ExecContext(gomock.Any()).Return(
 				"go.mod\n"+"go.sum\n"+"pkg/git_wrapper/branch.go",
 				nil).Times(1)
 			output, _ := commit.DiffListFileChanged(nil)
@@ -55,6 +58,168 @@ var _ = Describe("Commit unit test", func() {
 			}))
 		})
 	})
+
+	Context("AddedLineRanges(targetCommit *Commit, option *DiffOptions) ([]FileLineRanges, error)", func() {
+		It("Should merge consecutive added lines into ranges of the new revision", func() {
+			commit := NewCommit("ebc635acded8305a60fec5fad5b66d9d8c74d78f", "/tmp/scan")
+			targetCommit := NewCommit("99cdb715ac9cdad0f90f6af6df2757661b117efb", "/tmp/scan")
+			mockCommandBuilder.EXPECT().AddCommand("diff")
+			mockCommandBuilder.EXPECT().SetDir("/tmp/scan")
+			mockCommandBuilder.EXPECT().AddArgs([]string{"--no-color", "--no-ext-diff", "--src-prefix=a/", "--dst-prefix=b/", "--unified=0", "--find-renames", "99cdb715ac9cdad0f90f6af6df2757661b117efb", "ebc635acded8305a60fec5fad5b66d9d8c74d78f"})
+			mockCommandBuilder.EXPECT().ExecContext(gomock.Any()).Return(
+				"diff --git a/w.txt b/w.txt\nindex f9d9a01..0a75acf 100644\n--- a/w.txt\n+++ b/w.txt\n@@ -2 +2 @@ a\n-b\n+X\n@@ -4 +4 @@ c\n-d\n+d  \n@@ -6 +6,2 @@ e\n-f\n+Y\n+Z\n"+
+					"diff --git a/gone.txt b/gone.txt\ndeleted file mode 100644\nindex 587be6b..0000000\n--- a/gone.txt\n+++ /dev/null\n@@ -1 +0,0 @@\n-x\n"+
+					"diff --git a/bin.dat b/bin.dat\nindex bdc955b..8835708 100644\nBinary files a/bin.dat and b/bin.dat differ\n", nil)
+			ranges, err := commit.AddedLineRanges(&targetCommit, nil)
+			Expect(err).To(BeNil())
+			Expect(ranges).To(Equal([]FileLineRanges{
+				{Path: "w.txt", Ranges: []LineRange{{Start: 2, End: 2}, {Start: 4, End: 4}, {Start: 6, End: 7}}},
+			}))
+			Expect(ranges[0].Contains(7)).To(BeTrue())
+			Expect(ranges[0].Contains(5)).To(BeFalse())
+		})
+
+		It("Should ignore whitespace-only changes and compare the working tree without a target", func() {
+			commit := NewCommit("ebc635acded8305a60fec5fad5b66d9d8c74d78f", "/tmp/scan")
+			mockCommandBuilder.EXPECT().AddCommand("diff")
+			mockCommandBuilder.EXPECT().SetDir("/tmp/scan")
+			mockCommandBuilder.EXPECT().AddArgs([]string{"--no-color", "--no-ext-diff", "--src-prefix=a/", "--dst-prefix=b/", "--unified=0", "-w", "--find-renames", "ebc635acded8305a60fec5fad5b66d9d8c74d78f"})
+			mockCommandBuilder.EXPECT().ExecContext(gomock.Any()).Return(
+				"diff --git a/w.txt b/w.txt\nindex f9d9a01..0a75acf 100644\n--- a/w.txt\n+++ b/w.txt\n@@ -2 +2 @@ a\n-b\n+X\n@@ -6 +6,2 @@ e\n-f\n+Y\n+Z\n", nil)
+			ranges, err := commit.AddedLineRanges(nil, &DiffOptions{IgnoreWhitespace: true})
+			Expect(err).To(BeNil())
+			Expect(ranges).To(Equal([]FileLineRanges{
+				{Path: "w.txt", Ranges: []LineRange{{Start: 2, End: 2}, {Start: 6, End: 7}}},
+			}))
+		})
+	})
+
+	Context("DiffFileChanges(targetCommit *Commit, option *DiffOptions) ([]FileChange, error)", func() {
+		It("Should return the status and paths of every changed file", func() {
+			commit := NewCommit("ebc635acded8305a60fec5fad5b66d9d8c74d78f", "/tmp/scan")
+			targetCommit := NewCommit("99cdb715ac9cdad0f90f6af6df2757661b117efb", "/tmp/scan")
+			mockCommandBuilder.EXPECT().AddCommand("diff")
+			mockCommandBuilder.EXPECT().SetDir("/tmp/scan")
+			mockCommandBuilder.EXPECT().AddArgs([]string{"--name-status", "-z", "--find-renames", "99cdb715ac9cdad0f90f6af6df2757661b117efb", "ebc635acded8305a60fec5fad5b66d9d8c74d78f"})
+			mockCommandBuilder.EXPECT().ExecContext(gomock.Any()).Return(
+				"M\x00b.txt\x00C100\x00b.txt\x00b2.txt\x00R097\x00a.txt\x00c d.txt\x00D\x00gone\x00T\x00t\x00A\x00new.txt\x00", nil)
+			changes, err := commit.DiffFileChanges(&targetCommit, nil)
+			Expect(err).To(BeNil())
+			Expect(changes).To(Equal([]FileChange{
+				{Status: FileStatusModified, OldPath: "b.txt", NewPath: "b.txt"},
+				{Status: FileStatusCopied, OldPath: "b.txt", NewPath: "b2.txt", Similarity: 100},
+				{Status: FileStatusRenamed, OldPath: "a.txt", NewPath: "c d.txt", Similarity: 97},
+				{Status: FileStatusDeleted, OldPath: "gone"},
+				{Status: FileStatusTypeChanged, OldPath: "t", NewPath: "t"},
+				{Status: FileStatusAdded, NewPath: "new.txt"},
+			}))
+			Expect(changes[3].Path()).To(Equal("gone"))
+		})
+
+		It("Should pass detection thresholds and pathspecs", func() {
+			commit := NewCommit("ebc635acded8305a60fec5fad5b66d9d8c74d78f", "/tmp/scan")
+			mockCommandBuilder.EXPECT().AddCommand("diff")
+			mockCommandBuilder.EXPECT().SetDir("/tmp/scan")
+			mockCommandBuilder.EXPECT().AddArgs([]string{"--name-status", "-z", "--find-renames=90%", "--find-copies=75%", "--diff-filter=ACMRD", "ebc635acded8305a60fec5fad5b66d9d8c74d78f", "--", "src/", ":(exclude)vendor"})
+			mockCommandBuilder.EXPECT().ExecContext(gomock.Any()).Return("R090\x00src/a.go\x00src/b.go\x00", nil)
+			changes, err := commit.DiffFileChanges(nil, &DiffOptions{
+				RenameThreshold: 90,
+				FindCopies:      true,
+				CopyThreshold:   75,
+				DiffFilter:      "ACMRD",
+				Pathspecs:       []string{"src/", ":(exclude)vendor"},
+			})
+			Expect(err).To(BeNil())
+			Expect(changes).To(Equal([]FileChange{{Status: FileStatusRenamed, OldPath: "src/a.go", NewPath: "src/b.go", Similarity: 90}}))
+		})
+
+		It("Should disable rename detection", func() {
+			commit := NewCommit("ebc635acded8305a60fec5fad5b66d9d8c74d78f", "/tmp/scan")
+			mockCommandBuilder.EXPECT().AddCommand("diff")
+			mockCommandBuilder.EXPECT().SetDir("/tmp/scan")
+			mockCommandBuilder.EXPECT().AddArgs([]string{"--name-status", "-z", "--no-renames", "ebc635acded8305a60fec5fad5b66d9d8c74d78f"})
+			mockCommandBuilder.EXPECT().ExecContext(gomock.Any()).Return("D\x00a.go\x00A\x00b.go\x00", nil)
+			changes, err := commit.DiffFileChanges(nil, &DiffOptions{NoRenames: true})
+			Expect(err).To(BeNil())
+			Expect(changes).To(Equal([]FileChange{
+				{Status: FileStatusDeleted, OldPath: "a.go"},
+				{Status: FileStatusAdded, NewPath: "b.go"},
+			}))
+		})
+
+		It("Should reject truncated output", func() {
+			_, err := ParseNameStatus("R100\x00a.go\x00")
+			Expect(err).NotTo(BeNil())
+		})
+	})
+
+	Context("DiffShortStat(targetCommit *Commit) (DiffStat, error)", func() {
+		It("Should return the number of files, insertions and deletions", func() {
+			commit := NewCommit("ebc635acded8305a60fec5fad5b66d9d8c74d78f", "/tmp/scan")
+			targetCommit := NewCommit("99cdb715ac9cdad0f90f6af6df2757661b117efb", "/tmp/scan")
+			mockCommandBuilder.EXPECT().AddCommand("diff")
+			mockCommandBuilder.EXPECT().SetDir("/tmp/scan")
+			mockCommandBuilder.EXPECT().AddArgs([]string{"--shortstat", "--find-renames", "99cdb715ac9cdad0f90f6af6df2757661b117efb", "ebc635acded8305a60fec5fad5b66d9d8c74d78f"})
+			mockCommandBuilder.EXPECT().ExecContext(gomock.Any()).Return(" 5 files changed, 54 insertions(+), 2 deletions(-)\n", nil)
+			stat, err := commit.DiffShortStat(&targetCommit)
+			Expect(err).To(BeNil())
+			Expect(stat).To(Equal(DiffStat{FilesChanged: 5, Insertions: 54, Deletions: 2}))
+		})
+
+		It("Should handle missing parts and empty diffs", func() {
+			stat, err := ParseShortStat(" 1 file changed, 1 insertion(+)\n")
+			Expect(err).To(BeNil())
+			Expect(stat).To(Equal(DiffStat{FilesChanged: 1, Insertions: 1}))
+			stat, err = ParseShortStat("")
+			Expect(err).To(BeNil())
+			Expect(stat).To(Equal(DiffStat{}))
+		})
+	})
+
+	Context("DiffNumStat(targetCommit *Commit, option *DiffOptions) ([]FileNumStat, error)", func() {
+		It("Should return per file line counts with renames and binaries", func() {
+			commit := NewCommit("ebc635acded8305a60fec5fad5b66d9d8c74d78f", "/tmp/scan")
+			mockCommandBuilder.EXPECT().AddCommand("diff")
+			mockCommandBuilder.EXPECT().SetDir("/tmp/scan")
+			mockCommandBuilder.EXPECT().AddArgs([]string{"--numstat", "-z", "--find-renames", "ebc635acded8305a60fec5fad5b66d9d8c74d78f"})
+			mockCommandBuilder.EXPECT().ExecContext(gomock.Any()).Return(
+				"1\t0\tb.txt\x00-\t-\tbin\x001\t0\t\x00a.txt\x00c d.txt\x000\t1\tgone\x00", nil)
+			stats, err := commit.DiffNumStat(nil, nil)
+			Expect(err).To(BeNil())
+			Expect(stats).To(Equal([]FileNumStat{
+				{OldPath: "b.txt", NewPath: "b.txt", Added: 1},
+				{OldPath: "bin", NewPath: "bin", IsBinary: true},
+				{OldPath: "a.txt", NewPath: "c d.txt", Added: 1},
+				{OldPath: "gone", NewPath: "gone", Deleted: 1},
+			}))
+		})
+	})
+})
+
+var _ = Describe("Commit diff test", func() {
+	It("Should compare the same revisions in DiffListFileChanged and DiffFileChanges without a target", func() {
+		dest := initTestRepository(map[string]string{"a.txt": "a\n", "b.txt": "b\n"})
+		commit := NewCommit(runTestGit(dest, "rev-parse", "HEAD"), dest)
+		Expect(os.WriteFile(filepath.Join(dest, "a.txt"), []byte("a2\n"), 0o644)).Should(Succeed())
+		Expect(os.Remove(filepath.Join(dest, "b.txt"))).Should(Succeed())
+		Expect(os.WriteFile(filepath.Join(dest, "d.txt"), []byte("d\n"), 0o644)).Should(Succeed())
+		runTestGit(dest, "add", "d.txt")
+
+		listed, err := commit.DiffListFileChanged(nil)
+		Expect(err).To(BeNil())
+		Expect(listed).To(ConsistOf("a.txt", "d.txt"))
+
+		changes, err := commit.DiffFileChanges(nil, nil)
+		Expect(err).To(BeNil())
+		Expect(changes).To(ConsistOf(
+			FileChange{Status: FileStatusModified, OldPath: "a.txt", NewPath: "a.txt"},
+			FileChange{Status: FileStatusDeleted, OldPath: "b.txt"},
+			FileChange{Status: FileStatusAdded, NewPath: "d.txt"},
+		))
+		changes, err = commit.DiffFileChanges(nil, &DiffOptions{DiffFilter: "ACMR"})
+		Expect(err).To(BeNil())
+		Expect(lo.Map(changes, func(change FileChange, _ int) string { return change.Path() })).To(ConsistOf(listed))
+	})
 })
 
 func TestCommit_DiffListFileChanged(t *testing.T) {
@@ -62,12 +227,11 @@ func TestCommit_DiffListFileChanged(t *testing.T) {
 	var mockCommandBuilder *mock_git_wrapper.MockICommandBuilder
 	mockCtrl = gomock.NewController(t)
 	mockCommandBuilder = mock_git_wrapper.NewMockICommandBuilder(mockCtrl)
+	old := commandBuilderFunc
 	commandBuilderFunc = func() ICommandBuilder {
 		return mockCommandBuilder
 	}
 
-	old := commandBuilderFunc
-
 	defer func() { commandBuilderFunc = old }()
 
 	commit := NewCommit("ebc635acded8305a60fec5fad5b66d9d8c74d78f", "/tmp/scan")
@@ -77,7 +241,7 @@ func TestCommit_DiffListFileChanged(t *testing.T) {
 	mockCommandBuilder.EXPECT().AddArgs([]string{"--name-only", "--diff-filter=ACMR"})
 	mockCommandBuilder.EXPECT().AddArg("99cdb715ac9cdad0f90f6af6df2757661b117efb")
 	mockCommandBuilder.EXPECT().AddArg("ebc635acded8305a60fec5fad5b66d9d8c74d78f")
-	mockCommandBuilder.EXPECT().Exec().Return("pkg/git_wrapper/git.go", nil).Times(1)
+	mockCommandBuilder.EXPECT().ExecContext(gomock.Any()).Return("pkg/git_wrapper/git.go", nil).Times(1)
 	output, _ := commit.DiffListFileChanged(&targetCommit)
 	expected := []string{"pkg/git_wrapper/git.go"}
 	if !reflect.DeepEqual(output, expected) {
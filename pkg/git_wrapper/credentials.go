@@ -0,0 +1,89 @@
+package git_wrapper
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+)
+
+const (
+	credentialUsernameEnv = "OPERARIUS_GIT_USERNAME"
+	credentialPasswordEnv = "OPERARIUS_GIT_PASSWORD"
+	// credentialHelper answers git's credential requests from the environment
+	// of the git process, so the secret is neither in the argument list nor
+	// written to the repository config.
+	credentialHelper = `!f() { test "$1" = get || return 0; printf 'username=%s\npassword=%s\n' "$` + credentialUsernameEnv + `" "$` + credentialPasswordEnv + `"; }; f`
+)
+
+// Credentials authenticate git over https.
+type Credentials struct {
+	Username string
+	Password string
+}
+
+// apply hands the credentials to git through a credential helper reading them
+// from the environment. Helpers configured on the host are disabled so they
+// neither answer instead nor store the secret. The helper only answers for the
+// scheme and host of rawUrl, never for redirects, submodules or other remotes.
+func (c *Credentials) apply(commandBuilder ICommandBuilder, rawUrl string) error {
+	if c == nil || c.Password == "" {
+		return nil
+	}
+	// the helper prints them as lines of the credential protocol
+	if strings.ContainsAny(c.Username, "\r\n\x00") || strings.ContainsAny(c.Password, "\r\n\x00") {
+		return fmt.Errorf("credentials must not contain line breaks or NUL characters")
+	}
+	origin, err := credentialOrigin(rawUrl)
+	if err != nil {
+		return err
+	}
+	commandBuilder.AddSecret(c.Password)
+	commandBuilder.AddEnv("GIT_TERMINAL_PROMPT", "0")
+	commandBuilder.AddEnv(credentialUsernameEnv, c.Username)
+	commandBuilder.AddEnv(credentialPasswordEnv, c.Password)
+	// an empty value resets the list of helpers
+	commandBuilder.AddConfig("credential.helper", "")
+	commandBuilder.AddConfig("credential."+origin+".helper", credentialHelper)
+	return nil
+}
+
+// credentialOrigin returns the scheme://host[:port] of rawUrl, which git
+// matches credential.<url>.helper against.
+func credentialOrigin(rawUrl string) (string, error) {
+	target := rawUrl
+	if _, rest, found := strings.Cut(rawUrl, "@"); found && !strings.Contains(rawUrl, "://") {
+		// scp-like syntax of a remote fetched over https, git@github.com:owner/repo.git
+		target, _, _ = strings.Cut(rest, ":")
+	}
+	parsed, err := url.Parse(httpsURL(target))
+	if err != nil || parsed.Host == "" {
+		return "", fmt.Errorf("no host to pass credentials to in %q", redact(rawUrl, nil))
+	}
+	return parsed.Scheme + "://" + strings.ToLower(parsed.Host), nil
+}
+
+// splitURLCredentials removes the username and password from an https url
+// and returns them separately. Urls without a password, like ssh ones, are
+// returned unchanged.
+func splitURLCredentials(rawUrl string) (string, *Credentials) {
+	parsed, err := url.Parse(rawUrl)
+	if err != nil || parsed.User == nil {
+		return rawUrl, nil
+	}
+	password, hasPassword := parsed.User.Password()
+	if !hasPassword {
+		return rawUrl, nil
+	}
+	credentials := &Credentials{Username: parsed.User.Username(), Password: password}
+	parsed.User = nil
+	return parsed.String(), credentials
+}
+
+// httpsURL adds the https scheme to urls given without one, as CloneOptions
+// historically expected.
+func httpsURL(rawUrl string) string {
+	if strings.Contains(rawUrl, "://") {
+		return rawUrl
+	}
+	return "https://" + rawUrl
+}
@@ -0,0 +1,357 @@
+package git_wrapper
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+type FileStatus string
+
+const (
+	FileStatusAdded       FileStatus = "added"
+	FileStatusCopied      FileStatus = "copied"
+	FileStatusModified    FileStatus = "modified"
+	FileStatusRenamed     FileStatus = "renamed"
+	FileStatusDeleted     FileStatus = "deleted"
+	FileStatusTypeChanged FileStatus = "type-changed"
+)
+
+type LineKind int
+
+const (
+	LineContext LineKind = iota
+	LineAdded
+	LineDeleted
+)
+
+// DiffLine is a single line of a hunk. OldLineNumber is zero for added lines
+// and NewLineNumber is zero for deleted lines.
+type DiffLine struct {
+	Kind           LineKind
+	Content        string
+	OldLineNumber  int
+	NewLineNumber  int
+	NoNewlineAtEOF bool
+}
+
+type Hunk struct {
+	OldStart int
+	OldLines int
+	NewStart int
+	NewLines int
+	// Section is the function context git prints after the range, if any.
+	Section string
+	Lines   []DiffLine
+}
+
+type FileDiff struct {
+	OldPath    string
+	NewPath    string
+	Status     FileStatus
+	OldMode    string
+	NewMode    string
+	Similarity int
+	IsBinary   bool
+	Hunks      []Hunk
+}
+
+// AddedLines returns the lines introduced in the new revision.
+func (f FileDiff) AddedLines() []DiffLine {
+	added := []DiffLine{}
+	for _, hunk := range f.Hunks {
+		for _, line := range hunk.Lines {
+			if line.Kind == LineAdded {
+				added = append(added, line)
+			}
+		}
+	}
+	return added
+}
+
+func (r *Repository) GetDiffBetweenCommits(commit, target string) ([]FileDiff, error) {
+	return r.GetDiffBetweenCommitsContext(context.Background(), commit, target)
+}
+
+// GetDiffBetweenCommitsContext is the structured counterpart of
+// GetDiffContentBetweenCommitsContext.
+func (r *Repository) GetDiffBetweenCommitsContext(ctx context.Context, commit, target string) ([]FileDiff, error) {
+	commandBuilder := commandBuilderFunc()
+	commandBuilder.SetDir(r.Dest)
+	if commit == target {
+		commandBuilder.AddCommand("show")
+		commandBuilder.AddArgs(append([]string{"--format=", "--diff-merges=first-parent"}, patchArgs("--find-renames", commit)...))
+	} else {
+		commandBuilder.AddCommand("diff")
+		commandBuilder.AddArgs(patchArgs("--find-renames", fmt.Sprintf("%s..%s", target, commit)))
+	}
+	output, err := commandBuilder.ExecContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return ParseUnifiedDiff(output)
+}
+
+// patchArgs returns args after the flags producing the patch format
+// ParseUnifiedDiff reads. The a/ and b/ prefixes are explicit since the
+// repository config can change them with diff.noprefix or diff.mnemonicPrefix.
+func patchArgs(args ...string) []string {
+	return append([]string{"--no-color", "--no-ext-diff", "--src-prefix=a/", "--dst-prefix=b/"}, args...)
+}
+
+// ParseUnifiedDiff parses the output of `git diff` or `git show` in the
+// default patch format. Anything before the first `diff --git` line, such as
+// a commit header, is ignored.
+func ParseUnifiedDiff(output string) ([]FileDiff, error) {
+	files := []FileDiff{}
+	var current *FileDiff
+	var hunk *Hunk
+	oldLine, newLine, oldRemaining, newRemaining := 0, 0, 0, 0
+
+	scanner := bufio.NewScanner(strings.NewReader(output))
+	scanner.Buffer(make([]byte, 64*1024), 64*1024*1024)
+	flush := func() {
+		if current == nil {
+			return
+		}
+		if last := len(files) - 1; last >= 0 && isTypeChange(files[last], *current) {
+			// git writes a type change as the deletion of the path followed by
+			// its addition
+			changed := &files[last]
+			changed.Status = FileStatusTypeChanged
+			changed.NewPath = current.NewPath
+			changed.NewMode = current.NewMode
+			changed.IsBinary = changed.IsBinary || current.IsBinary
+			changed.Hunks = append(changed.Hunks, current.Hunks...)
+		} else {
+			files = append(files, *current)
+		}
+		current = nil
+	}
+	for scanner.Scan() {
+		line := scanner.Text()
+
+		if hunk != nil && (oldRemaining > 0 || newRemaining > 0) {
+			if len(line) == 0 {
+				// some tools strip the leading space of empty context lines
+				line = " "
+			}
+			switch line[0] {
+			case ' ':
+				hunk.Lines = append(hunk.Lines, DiffLine{Kind: LineContext, Content: line[1:], OldLineNumber: oldLine, NewLineNumber: newLine})
+				oldLine++
+				newLine++
+				oldRemaining--
+				newRemaining--
+				continue
+			case '-':
+				hunk.Lines = append(hunk.Lines, DiffLine{Kind: LineDeleted, Content: line[1:], OldLineNumber: oldLine})
+				oldLine++
+				oldRemaining--
+				continue
+			case '+':
+				hunk.Lines = append(hunk.Lines, DiffLine{Kind: LineAdded, Content: line[1:], NewLineNumber: newLine})
+				newLine++
+				newRemaining--
+				continue
+			case '\\':
+				markNoNewline(hunk)
+				continue
+			}
+			return nil, fmt.Errorf("unexpected line in hunk of %s: %q", current.NewPath, line)
+		}
+
+		if strings.HasPrefix(line, "diff --git ") {
+			flush()
+			hunk = nil
+			oldPath, newPath := parseDiffGitLine(strings.TrimPrefix(line, "diff --git "))
+			current = &FileDiff{OldPath: oldPath, NewPath: newPath, Status: FileStatusModified}
+			continue
+		}
+		if current == nil {
+			continue
+		}
+		switch {
+		case strings.HasPrefix(line, `\`):
+			if hunk != nil {
+				markNoNewline(hunk)
+			}
+		case strings.HasPrefix(line, "@@ "):
+			h, err := parseHunkHeader(line)
+			if err != nil {
+				return nil, err
+			}
+			current.Hunks = append(current.Hunks, h)
+			hunk = &current.Hunks[len(current.Hunks)-1]
+			oldLine, newLine = h.OldStart, h.NewStart
+			oldRemaining, newRemaining = h.OldLines, h.NewLines
+		case strings.HasPrefix(line, "old mode "):
+			current.OldMode = strings.TrimPrefix(line, "old mode ")
+		case strings.HasPrefix(line, "new mode "):
+			current.NewMode = strings.TrimPrefix(line, "new mode ")
+		case strings.HasPrefix(line, "new file mode "):
+			current.Status = FileStatusAdded
+			current.NewMode = strings.TrimPrefix(line, "new file mode ")
+		case strings.HasPrefix(line, "deleted file mode "):
+			current.Status = FileStatusDeleted
+			current.OldMode = strings.TrimPrefix(line, "deleted file mode ")
+		case strings.HasPrefix(line, "similarity index "):
+			current.Similarity, _ = strconv.Atoi(strings.TrimSuffix(strings.TrimPrefix(line, "similarity index "), "%"))
+		case strings.HasPrefix(line, "rename from "):
+			current.Status = FileStatusRenamed
+			current.OldPath = unquotePath(strings.TrimPrefix(line, "rename from "))
+		case strings.HasPrefix(line, "rename to "):
+			current.NewPath = unquotePath(strings.TrimPrefix(line, "rename to "))
+		case strings.HasPrefix(line, "copy from "):
+			current.Status = FileStatusCopied
+			current.OldPath = unquotePath(strings.TrimPrefix(line, "copy from "))
+		case strings.HasPrefix(line, "copy to "):
+			current.NewPath = unquotePath(strings.TrimPrefix(line, "copy to "))
+		case strings.HasPrefix(line, "index "):
+			// index <old>..<new> <mode> carries the mode of unchanged-mode files
+			fields := strings.Fields(line)
+			if len(fields) == 3 && current.OldMode == "" && current.NewMode == "" {
+				current.OldMode = fields[2]
+				current.NewMode = fields[2]
+			}
+		case strings.HasPrefix(line, "Binary files "), line == "GIT binary patch":
+			current.IsBinary = true
+		case strings.HasPrefix(line, "--- "):
+			// git terminates paths containing spaces with a tab
+			if path := strings.TrimSuffix(strings.TrimPrefix(line, "--- "), "\t"); path != "/dev/null" {
+				current.OldPath = trimDiffPrefix(unquotePath(path), "a/")
+			}
+		case strings.HasPrefix(line, "+++ "):
+			if path := strings.TrimSuffix(strings.TrimPrefix(line, "+++ "), "\t"); path != "/dev/null" {
+				current.NewPath = trimDiffPrefix(unquotePath(path), "b/")
+			}
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	flush()
+	return files, nil
+}
+
+func markNoNewline(hunk *Hunk) {
+	if len(hunk.Lines) > 0 {
+		hunk.Lines[len(hunk.Lines)-1].NoNewlineAtEOF = true
+	}
+}
+
+// parseHunkHeader parses "@@ -oldStart[,oldLines] +newStart[,newLines] @@ section".
+func parseHunkHeader(line string) (Hunk, error) {
+	hunk := Hunk{}
+	rest := strings.TrimPrefix(line, "@@ ")
+	ranges, section, found := strings.Cut(rest, " @@")
+	if !found {
+		return hunk, fmt.Errorf("malformed hunk header %q", line)
+	}
+	hunk.Section = strings.TrimPrefix(section, " ")
+	oldRange, newRange, found := strings.Cut(ranges, " ")
+	if !found || !strings.HasPrefix(oldRange, "-") || !strings.HasPrefix(newRange, "+") {
+		return hunk, fmt.Errorf("malformed hunk header %q", line)
+	}
+	var err error
+	if hunk.OldStart, hunk.OldLines, err = parseHunkRange(oldRange[1:]); err != nil {
+		return hunk, fmt.Errorf("malformed hunk header %q: %w", line, err)
+	}
+	if hunk.NewStart, hunk.NewLines, err = parseHunkRange(newRange[1:]); err != nil {
+		return hunk, fmt.Errorf("malformed hunk header %q: %w", line, err)
+	}
+	return hunk, nil
+}
+
+func parseHunkRange(value string) (int, int, error) {
+	startValue, linesValue, hasLines := strings.Cut(value, ",")
+	start, err := strconv.Atoi(startValue)
+	if err != nil {
+		return 0, 0, err
+	}
+	lines := 1
+	if hasLines {
+		if lines, err = strconv.Atoi(linesValue); err != nil {
+			return 0, 0, err
+		}
+	}
+	return start, lines, nil
+}
+
+// parseDiffGitLine extracts both paths from "a/<old> b/<new>". Paths are only
+// used until more precise headers (---/+++, rename from/to) are seen, which
+// matters for binary and mode-only changes where those headers are missing.
+func parseDiffGitLine(value string) (string, string) {
+	if strings.HasPrefix(value, `"`) {
+		oldPath, rest := splitQuoted(value)
+		return trimDiffPrefix(oldPath, "a/"), trimDiffPrefix(unquotePath(strings.TrimPrefix(rest, " ")), "b/")
+	}
+	if strings.HasSuffix(value, `"`) {
+		idx := strings.Index(value, ` "`)
+		if idx >= 0 {
+			return trimDiffPrefix(value[:idx], "a/"), trimDiffPrefix(unquotePath(value[idx+1:]), "b/")
+		}
+	}
+	// Without a rename both halves are the same path, which disambiguates
+	// paths that themselves contain " b/".
+	if (len(value)-1)%2 == 0 {
+		half := (len(value) - 1) / 2
+		oldPath, newPath := value[:half], value[half+1:]
+		if strings.HasPrefix(oldPath, "a/") && strings.HasPrefix(newPath, "b/") && oldPath[2:] == newPath[2:] {
+			return oldPath[2:], newPath[2:]
+		}
+	}
+	if idx := strings.Index(value, " b/"); idx >= 0 {
+		return trimDiffPrefix(value[:idx], "a/"), value[idx+3:]
+	}
+	return value, value
+}
+
+func splitQuoted(value string) (string, string) {
+	escaped := false
+	for i := 1; i < len(value); i++ {
+		switch {
+		case escaped:
+			escaped = false
+		case value[i] == '\\':
+			escaped = true
+		case value[i] == '"':
+			return unquotePath(value[:i+1]), value[i+1:]
+		}
+	}
+	return value, ""
+}
+
+// unquotePath undoes git's C-style quoting of paths with special characters.
+func unquotePath(path string) string {
+	if len(path) >= 2 && strings.HasPrefix(path, `"`) && strings.HasSuffix(path, `"`) {
+		if unquoted, err := strconv.Unquote(path); err == nil {
+			return unquoted
+		}
+	}
+	return path
+}
+
+// isTypeChange tells whether added is the other half of the type change of
+// deleted, such as a file replaced by a symlink or a submodule.
+func isTypeChange(deleted FileDiff, added FileDiff) bool {
+	return deleted.Status == FileStatusDeleted && added.Status == FileStatusAdded &&
+		deleted.OldPath == added.NewPath && modeObjectType(deleted.OldMode) != modeObjectType(added.NewMode)
+}
+
+// modeObjectType returns the object type bits of an octal git mode, telling a
+// regular file (100644, 100755) from a symlink (120000) or a submodule
+// (160000).
+func modeObjectType(mode string) uint64 {
+	value, err := strconv.ParseUint(mode, 8, 32)
+	if err != nil {
+		return 0
+	}
+	return value >> 12
+}
+
+func trimDiffPrefix(path string, prefix string) string {
+	return strings.TrimPrefix(path, prefix)
+}
@@ -0,0 +1,185 @@
+package git_wrapper
+
+import (
+	mock_git_wrapper "operarius/mock/pkg/git_wrapper"
+	"os"
+	"path/filepath"
+
+	"github.com/golang/mock/gomock"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+const sampleDiff = "diff --git a/bin.dat b/bin.dat\nindex bdc955b..8835708 100644\nBinary files a/bin.dat and b/bin.dat differ\n" +
+	"diff --git a/mode.sh b/mode.sh\nold mode 100644\nnew mode 100755\n" +
+	"diff --git a/new file.txt b/new file.txt\nnew file mode 100644\nindex 0000000..3e75765\n--- /dev/null\n+++ b/new file.txt\t\n@@ -0,0 +1 @@\n+new\n" +
+	"diff --git a/ren.txt b/renamed.txt\nsimilarity index 82%\nrename from ren.txt\nrename to renamed.txt\nindex b566061..2019eda 100644\n--- a/ren.txt\n+++ b/renamed.txt\n@@ -4,3 +4,4 @@ three\n four\n five\n six\n+seven\n" +
+	"diff --git a/sp ace.txt b/sp ace.txt\nindex de98044..36ef1ba 100644\n--- a/sp ace.txt\t\n+++ b/sp ace.txt\t\n@@ -1,3 +1,3 @@\n a\n-b\n-c\n+B\n+c\n\\ No newline at end of file\n" +
+	"diff --git \"a/\\303\\251.txt\" \"b/\\303\\251.txt\"\ndeleted file mode 100644\nindex 587be6b..0000000\n--- \"a/\\303\\251.txt\"\n+++ /dev/null\n@@ -1 +0,0 @@\n-x\n"
+
+var _ = Describe("Diff unit test", func() {
+	Context("ParseUnifiedDiff(output string) ([]FileDiff, error)", func() {
+		var files []FileDiff
+		BeforeEach(func() {
+			var err error
+			files, err = ParseUnifiedDiff(sampleDiff)
+			Expect(err).Should(BeNil())
+			Expect(files).Should(HaveLen(6))
+		})
+
+		It("Should flag binary files", func() {
+			Expect(files[0]).Should(Equal(FileDiff{
+				OldPath:  "bin.dat",
+				NewPath:  "bin.dat",
+				Status:   FileStatusModified,
+				OldMode:  "100644",
+				NewMode:  "100644",
+				IsBinary: true,
+			}))
+		})
+
+		It("Should record mode changes", func() {
+			Expect(files[1]).Should(Equal(FileDiff{
+				OldPath: "mode.sh",
+				NewPath: "mode.sh",
+				Status:  FileStatusModified,
+				OldMode: "100644",
+				NewMode: "100755",
+			}))
+		})
+
+		It("Should parse added files with spaces in their path", func() {
+			Expect(files[2].Status).Should(Equal(FileStatusAdded))
+			Expect(files[2].NewPath).Should(Equal("new file.txt"))
+			Expect(files[2].NewMode).Should(Equal("100644"))
+			Expect(files[2].Hunks).Should(Equal([]Hunk{
+				{
+					OldStart: 0, OldLines: 0, NewStart: 1, NewLines: 1,
+					Lines: []DiffLine{{Kind: LineAdded, Content: "new", NewLineNumber: 1}},
+				},
+			}))
+		})
+
+		It("Should parse renames with similarity and section headings", func() {
+			Expect(files[3].Status).Should(Equal(FileStatusRenamed))
+			Expect(files[3].OldPath).Should(Equal("ren.txt"))
+			Expect(files[3].NewPath).Should(Equal("renamed.txt"))
+			Expect(files[3].Similarity).Should(Equal(82))
+			Expect(files[3].Hunks[0].Section).Should(Equal("three"))
+			Expect(files[3].AddedLines()).Should(Equal([]DiffLine{{Kind: LineAdded, Content: "seven", NewLineNumber: 7}}))
+		})
+
+		It("Should number old and new lines and mark missing newlines", func() {
+			Expect(files[4].Hunks[0].Lines).Should(Equal([]DiffLine{
+				{Kind: LineContext, Content: "a", OldLineNumber: 1, NewLineNumber: 1},
+				{Kind: LineDeleted, Content: "b", OldLineNumber: 2},
+				{Kind: LineDeleted, Content: "c", OldLineNumber: 3},
+				{Kind: LineAdded, Content: "B", NewLineNumber: 2},
+				{Kind: LineAdded, Content: "c", NewLineNumber: 3, NoNewlineAtEOF: true},
+			}))
+		})
+
+		It("Should unquote paths with special characters of deleted files", func() {
+			Expect(files[5].Status).Should(Equal(FileStatusDeleted))
+			Expect(files[5].OldPath).Should(Equal("é.txt"))
+			Expect(files[5].OldMode).Should(Equal("100644"))
+			Expect(files[5].Hunks[0].Lines).Should(Equal([]DiffLine{{Kind: LineDeleted, Content: "x", OldLineNumber: 1}}))
+		})
+
+		It("Should treat content lines looking like headers as hunk lines", func() {
+			files, err := ParseUnifiedDiff("diff --git a/x b/x\n--- a/x\n+++ b/x\n@@ -1,2 +1,2 @@\n--- a/y\n+++ b/y\n-diff --git a/z b/z\n+@@ -1 +1 @@\n")
+			Expect(err).Should(BeNil())
+			Expect(files[0].Hunks[0].Lines).Should(Equal([]DiffLine{
+				{Kind: LineDeleted, Content: "-- a/y", OldLineNumber: 1},
+				{Kind: LineAdded, Content: "++ b/y", NewLineNumber: 1},
+				{Kind: LineDeleted, Content: "diff --git a/z b/z", OldLineNumber: 2},
+				{Kind: LineAdded, Content: "@@ -1 +1 @@", NewLineNumber: 2},
+			}))
+		})
+
+		It("Should return an error for a malformed hunk header", func() {
+			_, err := ParseUnifiedDiff("diff --git a/x b/x\n@@ -a +1 @@\n")
+			Expect(err).ShouldNot(BeNil())
+		})
+	})
+
+	Context("GetDiffBetweenCommits(commit, target string) ([]FileDiff, error)", func() {
+		var mockCtrl *gomock.Controller
+		var mockCommandBuilder *mock_git_wrapper.MockICommandBuilder
+		old := commandBuilderFunc
+		BeforeEach(func() {
+			mockCtrl = gomock.NewController(GinkgoT())
+			mockCommandBuilder = mock_git_wrapper.NewMockICommandBuilder(mockCtrl)
+			commandBuilderFunc = func() ICommandBuilder {
+				return mockCommandBuilder
+			}
+		})
+		AfterEach(func() {
+			defer func() { commandBuilderFunc = old }()
+		})
+
+		It("Should diff the range between target and commit", func() {
+			repository := NewRepository("kai-repo", "./tmp/kai-test")
+			mockCommandBuilder.EXPECT().SetDir("./tmp/kai-test")
+			mockCommandBuilder.EXPECT().AddCommand("diff")
+			mockCommandBuilder.EXPECT().AddArgs([]string{"--no-color", "--no-ext-diff", "--src-prefix=a/", "--dst-prefix=b/", "--find-renames", "99cdb71..ebc635a"})
+			mockCommandBuilder.EXPECT().ExecContext(gomock.Any()).Return(sampleDiff, nil)
+			files, err := repository.GetDiffBetweenCommits("ebc635a", "99cdb71")
+			Expect(err).Should(BeNil())
+			Expect(files).Should(HaveLen(6))
+		})
+
+		It("Should show a single commit against its first parent", func() {
+			repository := NewRepository("kai-repo", "./tmp/kai-test")
+			mockCommandBuilder.EXPECT().SetDir("./tmp/kai-test")
+			mockCommandBuilder.EXPECT().AddCommand("show")
+			mockCommandBuilder.EXPECT().AddArgs([]string{"--format=", "--diff-merges=first-parent", "--no-color", "--no-ext-diff", "--src-prefix=a/", "--dst-prefix=b/", "--find-renames", "ebc635a"})
+			mockCommandBuilder.EXPECT().ExecContext(gomock.Any()).Return(sampleDiff, nil)
+			files, err := repository.GetDiffBetweenCommits("ebc635a", "ebc635a")
+			Expect(err).Should(BeNil())
+			Expect(files).Should(HaveLen(6))
+		})
+	})
+
+	Context("GetDiffBetweenCommits on a repository", func() {
+		It("Should parse paths whatever diff prefixes the repository configures", func() {
+			// a/ also starts the path, which a prefix-less diff would lose
+			dest := initTestRepository(map[string]string{"a/x.txt": "a\n"})
+			first := runTestGit(dest, "rev-parse", "HEAD")
+			x := "b\n"
+			second := commitTestFiles(dest, map[string]*string{"a/x.txt": &x})
+			runTestGit(dest, "config", "diff.noprefix", "true")
+			files, err := NewRepository("", dest).GetDiffBetweenCommits(second, first)
+			Expect(err).Should(BeNil())
+			Expect(files).Should(HaveLen(1))
+			Expect(files[0].OldPath).Should(Equal("a/x.txt"))
+			Expect(files[0].NewPath).Should(Equal("a/x.txt"))
+
+			// the working tree is compared with c/ and w/ prefixes
+			runTestGit(dest, "config", "--unset", "diff.noprefix")
+			runTestGit(dest, "config", "diff.mnemonicPrefix", "true")
+			Expect(os.WriteFile(filepath.Join(dest, "a/x.txt"), []byte("c\n"), 0o644)).Should(Succeed())
+			ranges, err := NewCommit(second, dest).AddedLineRanges(nil, nil)
+			Expect(err).Should(BeNil())
+			Expect(ranges).Should(Equal([]FileLineRanges{{Path: "a/x.txt", Ranges: []LineRange{{Start: 1, End: 1}}}}))
+		})
+
+		It("Should merge the deletion and addition of a replaced file into a type change", func() {
+			dest := initTestRepository(map[string]string{"link": "hello\n", "other.txt": "o\n"})
+			first := runTestGit(dest, "rev-parse", "HEAD")
+			Expect(os.Remove(filepath.Join(dest, "link"))).Should(Succeed())
+			Expect(os.Symlink("target", filepath.Join(dest, "link"))).Should(Succeed())
+			second := commitTestFiles(dest, map[string]*string{"other.txt": nil})
+			files, err := NewRepository("", dest).GetDiffBetweenCommits(second, first)
+			Expect(err).Should(BeNil())
+			Expect(files).Should(HaveLen(2))
+			Expect(files[0].Status).Should(Equal(FileStatusTypeChanged))
+			Expect(files[0].OldPath).Should(Equal("link"))
+			Expect(files[0].NewPath).Should(Equal("link"))
+			Expect(files[0].OldMode).Should(Equal("100644"))
+			Expect(files[0].NewMode).Should(Equal("120000"))
+			Expect(files[0].AddedLines()).Should(Equal([]DiffLine{{Kind: LineAdded, Content: "target", NewLineNumber: 1, NoNewlineAtEOF: true}}))
+			Expect(files[1].Status).Should(Equal(FileStatusDeleted))
+		})
+	})
+})
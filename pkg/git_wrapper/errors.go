@@ -0,0 +1,181 @@
+package git_wrapper
+
+import (
+	"errors"
+	"fmt"
+	"os/exec"
+	"strings"
+
+	"operarius/internal/utils"
+)
+
+type ErrorKind int
+
+const (
+	ErrorKindUnknown ErrorKind = iota
+	ErrorKindAuth
+	ErrorKindNotFound
+	ErrorKindNetwork
+	ErrorKindLockContention
+	ErrorKindDiskFull
+	ErrorKindBadRef
+	ErrorKindOutOfMemory
+)
+
+func (k ErrorKind) String() string {
+	switch k {
+	case ErrorKindAuth:
+		return "auth"
+	case ErrorKindNotFound:
+		return "not-found"
+	case ErrorKindNetwork:
+		return "network"
+	case ErrorKindLockContention:
+		return "lock-contention"
+	case ErrorKindDiskFull:
+		return "disk-full"
+	case ErrorKindBadRef:
+		return "bad-ref"
+	case ErrorKindOutOfMemory:
+		return "out-of-memory"
+	}
+	return "unknown"
+}
+
+// Sentinel errors matched by GitError through errors.Is.
+var (
+	ErrAuthFailed         = errors.New("git: authentication failed")
+	ErrRepositoryNotFound = errors.New("git: repository not found")
+	ErrNetwork            = errors.New("git: network failure")
+	ErrLockContention     = errors.New("git: lock file exists")
+	ErrDiskFull           = errors.New("git: no space left on device")
+	ErrBadRef             = errors.New("git: bad reference")
+	// ErrOutOfMemory is kept equal to the historical utils error so existing
+	// errors.Is checks against utils.ErrCloneFailedDueToLackofMemory still hold.
+	ErrOutOfMemory = utils.ErrCloneFailedDueToLackofMemory
+)
+
+var errorKindSentinels = map[ErrorKind]error{
+	ErrorKindAuth:           ErrAuthFailed,
+	ErrorKindNotFound:       ErrRepositoryNotFound,
+	ErrorKindNetwork:        ErrNetwork,
+	ErrorKindLockContention: ErrLockContention,
+	ErrorKindDiskFull:       ErrDiskFull,
+	ErrorKindBadRef:         ErrBadRef,
+	ErrorKindOutOfMemory:    ErrOutOfMemory,
+}
+
+// Patterns are checked in order, the first match wins. Resource exhaustion
+// comes first because it usually surfaces as a side effect in other messages.
+var errorKindPatterns = []struct {
+	kind     ErrorKind
+	patterns []string
+}{
+	{ErrorKindOutOfMemory, []string{"fatal: fetch-pack", "fatal: early eof", "out of memory", "cannot allocate memory"}},
+	{ErrorKindDiskFull, []string{"no space left on device", "disk quota exceeded"}},
+	{ErrorKindLockContention, []string{".lock': file exists", "another git process seems to be running", "unable to create '", "is locked"}},
+	{ErrorKindAuth, []string{
+		"authentication failed",
+		"could not read username",
+		"could not read password",
+		"terminal prompts disabled",
+		"permission denied (publickey",
+		"http basic: access denied",
+		"the requested url returned error: 401",
+		"the requested url returned error: 403",
+		"invalid username or password",
+	}},
+	{ErrorKindNotFound, []string{
+		"repository not found",
+		"does not appear to be a git repository",
+		"the requested url returned error: 404",
+		"not a git repository",
+	}},
+	{ErrorKindBadRef, []string{
+		"unknown revision",
+		"bad revision",
+		"invalid reference",
+		"not a valid object name",
+		"couldn't find remote ref",
+		"ambiguous argument",
+		"bad object",
+		"invalid object name",
+		"not a valid ref",
+		"did not match any file(s) known to git",
+	}},
+	{ErrorKindNetwork, []string{
+		"could not resolve host",
+		"connection timed out",
+		"connection refused",
+		"failed to connect to",
+		"network is unreachable",
+		"the remote end hung up unexpectedly",
+		"rpc failed",
+		"operation timed out",
+		"ssl_error",
+		"gnutls_handshake",
+		"unable to access",
+	}},
+}
+
+// GitError describes a failed git invocation.
+type GitError struct {
+	Kind     ErrorKind
+	ExitCode int
+	// Command is the command line with credentials masked.
+	Command string
+	Stderr  string
+	Err     error
+}
+
+func (e *GitError) Error() string {
+	msg := e.Stderr
+	if msg == "" && e.Err != nil {
+		msg = e.Err.Error()
+	}
+	return fmt.Sprintf("%s: exit status %d: %s", e.Command, e.ExitCode, msg)
+}
+
+func (e *GitError) Unwrap() error {
+	return e.Err
+}
+
+func (e *GitError) Is(target error) bool {
+	sentinel, ok := errorKindSentinels[e.Kind]
+	return ok && sentinel == target
+}
+
+func newGitError(baseCommand string, args []string, err error, stderr string) *GitError {
+	stderr = strings.TrimSpace(stderr)
+	exitCode := -1
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		exitCode = exitErr.ExitCode()
+	}
+	return &GitError{
+		Kind:     ClassifyError(stderr),
+		ExitCode: exitCode,
+		Command:  redactCommand(baseCommand, args),
+		Stderr:   redact(stderr, nil),
+		Err:      err,
+	}
+}
+
+// ClassifyError maps git's stderr output to an ErrorKind.
+func ClassifyError(stderr string) ErrorKind {
+	lower := strings.ToLower(stderr)
+	for _, p := range errorKindPatterns {
+		for _, pattern := range p.patterns {
+			if strings.Contains(lower, pattern) {
+				return p.kind
+			}
+		}
+	}
+	return ErrorKindUnknown
+}
+
+// IsErrorKind reports whether err is a GitError of the given kind.
+func IsErrorKind(err error, kind ErrorKind) bool {
+	var gitErr *GitError
+	return errors.As(err, &gitErr) && gitErr.Kind == kind
+}
@@ -0,0 +1,175 @@
+package git_wrapper
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+)
+
+const (
+	// DefaultDeepenStep is the number of commits fetched by the first round of
+	// DeepenUntilCommit, doubled on every following round.
+	DefaultDeepenStep = 50
+	maxDeepenRounds   = 8
+)
+
+type FetchOptions struct {
+	// Remote defaults to origin when RefSpecs are given.
+	Remote string
+	// RefSpecs are fetched instead of the configured ones, e.g.
+	// "+refs/heads/main:refs/remotes/origin/main" or a commit sha.
+	RefSpecs []string
+	// Depth and ShallowSince truncate the history to the given number of
+	// commits from the tips or to commits newer than the given time.
+	Depth        int
+	ShallowSince time.Time
+	// Deepen extends the current shallow boundary by the given number of commits.
+	Deepen    int
+	Unshallow bool
+	NoTags    bool
+}
+
+func (o *FetchOptions) args() []string {
+	args := []string{}
+	if o == nil {
+		return args
+	}
+	if o.Depth > 0 {
+		args = append(args, fmt.Sprintf("--depth=%d", o.Depth))
+	}
+	if !o.ShallowSince.IsZero() {
+		args = append(args, "--shallow-since="+o.ShallowSince.Format(time.RFC3339))
+	}
+	if o.Deepen > 0 {
+		args = append(args, fmt.Sprintf("--deepen=%d", o.Deepen))
+	}
+	if o.Unshallow {
+		args = append(args, "--unshallow")
+	}
+	if o.NoTags {
+		args = append(args, "--no-tags")
+	}
+	if len(o.RefSpecs) > 0 {
+		remote := o.Remote
+		if remote == "" {
+			remote = "origin"
+		}
+		args = append(append(args, remote), o.RefSpecs...)
+	} else if o.Remote != "" {
+		args = append(args, o.Remote)
+	}
+	return args
+}
+
+func (r *Repository) FetchWithOptions(option *FetchOptions) error {
+	return r.FetchWithOptionsContext(context.Background(), option)
+}
+
+func (r *Repository) FetchWithOptionsContext(ctx context.Context, option *FetchOptions) error {
+	if option != nil {
+		for _, value := range append([]string{option.Remote}, option.RefSpecs...) {
+			if strings.HasPrefix(value, "-") {
+				return fmt.Errorf("invalid remote or refspec %q", value)
+			}
+		}
+	}
+	commandBuilder := commandBuilderFunc()
+	cleanup, err := r.addAuth(commandBuilder)
+	if err != nil {
+		return err
+	}
+	defer cleanup()
+	commandBuilder.SetDir(r.Dest)
+	commandBuilder.AddCommand("fetch")
+	if args := option.args(); len(args) > 0 {
+		commandBuilder.AddArgs(args)
+	}
+	_, err = commandBuilder.ExecContext(ctx)
+	return err
+}
+
+func (r *Repository) FetchCommit(commitSHA string) error {
+	return r.FetchCommitContext(context.Background(), commitSHA)
+}
+
+// FetchCommitContext fetches a single commit from origin by its full sha. The
+// server has to allow it, which GitHub, GitLab and Bitbucket do for any
+// reachable commit.
+func (r *Repository) FetchCommitContext(ctx context.Context, commitSHA string) error {
+	return r.FetchWithOptionsContext(ctx, &FetchOptions{RefSpecs: []string{commitSHA}})
+}
+
+func (r *Repository) IsShallow() (bool, error) {
+	return r.IsShallowContext(context.Background())
+}
+
+func (r *Repository) IsShallowContext(ctx context.Context) (bool, error) {
+	commandBuilder := commandBuilderFunc()
+	commandBuilder.SetDir(r.Dest)
+	commandBuilder.AddCommand("rev-parse")
+	commandBuilder.AddArg("--is-shallow-repository")
+	output, err := commandBuilder.ExecContext(ctx)
+	if err != nil {
+		return false, err
+	}
+	return strings.TrimSpace(output) == "true", nil
+}
+
+func (r *Repository) HasCommit(commitSHA string) (bool, error) {
+	return r.HasCommitContext(context.Background(), commitSHA)
+}
+
+// HasCommitContext reports whether commitSHA is present in the object store.
+func (r *Repository) HasCommitContext(ctx context.Context, commitSHA string) (bool, error) {
+	if commitSHA == "" || strings.HasPrefix(commitSHA, "-") {
+		return false, fmt.Errorf("invalid commit %q", commitSHA)
+	}
+	commandBuilder := commandBuilderFunc()
+	commandBuilder.SetDir(r.Dest)
+	commandBuilder.AddCommand("cat-file")
+	commandBuilder.AddArgs([]string{"-e", commitSHA + "^{commit}"})
+	_, err := commandBuilder.ExecContext(ctx)
+	if IsErrorKind(err, ErrorKindBadRef) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+func (r *Repository) DeepenUntilCommit(commitSHA string, step int) error {
+	return r.DeepenUntilCommitContext(context.Background(), commitSHA, step)
+}
+
+// DeepenUntilCommitContext deepens a shallow repository until commitSHA, for
+// instance the base of a diff, is available. Each round fetches twice as many
+// commits as the previous one, starting from step, and the remaining history
+// is fetched at once after maxDeepenRounds. It returns ErrObjectNotFound when
+// the full history doesn't contain the commit.
+func (r *Repository) DeepenUntilCommitContext(ctx context.Context, commitSHA string, step int) error {
+	if step <= 0 {
+		step = DefaultDeepenStep
+	}
+	for round := 0; ; round++ {
+		found, err := r.HasCommitContext(ctx, commitSHA)
+		if err != nil || found {
+			return err
+		}
+		shallow, err := r.IsShallowContext(ctx)
+		if err != nil {
+			return err
+		}
+		if !shallow {
+			return fmt.Errorf("%w: %s", ErrObjectNotFound, commitSHA)
+		}
+		option := &FetchOptions{Deepen: step << round}
+		if round >= maxDeepenRounds {
+			option = &FetchOptions{Unshallow: true}
+		}
+		if err := r.FetchWithOptionsContext(ctx, option); err != nil {
+			return err
+		}
+	}
+}
@@ -0,0 +1,134 @@
+package git_wrapper
+
+import (
+	"errors"
+	mock_git_wrapper "operarius/mock/pkg/git_wrapper"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Fetch unit test", func() {
+	var mockCtrl *gomock.Controller
+	var mockCommandBuilder *mock_git_wrapper.MockICommandBuilder
+	old := commandBuilderFunc
+	BeforeEach(func() {
+		mockCtrl = gomock.NewController(GinkgoT())
+		mockCommandBuilder = mock_git_wrapper.NewMockICommandBuilder(mockCtrl)
+		commandBuilderFunc = func() ICommandBuilder {
+			return mockCommandBuilder
+		}
+	})
+	AfterEach(func() {
+		defer func() { commandBuilderFunc = old }()
+	})
+
+	badRef := func() error {
+		return newGitError("git", []string{"cat-file", "-e"}, errors.New("exit status 128"), "fatal: Not a valid object name abc^{commit}")
+	}
+
+	Context("FetchWithOptions(option *FetchOptions) error", func() {
+		It("Should pass the shallow options", func() {
+			repository := NewRepository("", "/tmp/scan")
+			mockCommandBuilder.EXPECT().SetDir("/tmp/scan")
+			mockCommandBuilder.EXPECT().AddCommand("fetch")
+			mockCommandBuilder.EXPECT().AddArgs([]string{"--depth=10", "--shallow-since=2026-01-02T03:04:05Z", "--no-tags"})
+			mockCommandBuilder.EXPECT().ExecContext(gomock.Any())
+			Expect(repository.FetchWithOptions(&FetchOptions{
+				Depth:        10,
+				ShallowSince: time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC),
+				NoTags:       true,
+			})).Should(Succeed())
+		})
+
+		It("Should fetch explicit refspecs from origin", func() {
+			repository := NewRepository("", "/tmp/scan")
+			mockCommandBuilder.EXPECT().SetDir("/tmp/scan")
+			mockCommandBuilder.EXPECT().AddCommand("fetch")
+			mockCommandBuilder.EXPECT().AddArgs([]string{"--depth=1", "origin", "+refs/heads/main:refs/remotes/origin/main"})
+			mockCommandBuilder.EXPECT().ExecContext(gomock.Any())
+			Expect(repository.FetchWithOptions(&FetchOptions{
+				Depth:    1,
+				RefSpecs: []string{"+refs/heads/main:refs/remotes/origin/main"},
+			})).Should(Succeed())
+		})
+
+		It("Should fetch a single commit", func() {
+			repository := NewRepository("", "/tmp/scan")
+			mockCommandBuilder.EXPECT().SetDir("/tmp/scan")
+			mockCommandBuilder.EXPECT().AddCommand("fetch")
+			mockCommandBuilder.EXPECT().AddArgs([]string{"origin", "ebc635acded8305a60fec5fad5b66d9d8c74d78f"})
+			mockCommandBuilder.EXPECT().ExecContext(gomock.Any())
+			Expect(repository.FetchCommit("ebc635acded8305a60fec5fad5b66d9d8c74d78f")).Should(Succeed())
+		})
+
+		It("Should reject refspecs that look like options", func() {
+			repository := NewRepository("", "/tmp/scan")
+			Expect(repository.FetchWithOptions(&FetchOptions{RefSpecs: []string{"--upload-pack=touch /tmp/x"}})).ShouldNot(Succeed())
+		})
+
+		It("Should run a plain fetch without options", func() {
+			repository := NewRepository("", "/tmp/scan")
+			mockCommandBuilder.EXPECT().SetDir("/tmp/scan")
+			mockCommandBuilder.EXPECT().AddCommand("fetch")
+			mockCommandBuilder.EXPECT().ExecContext(gomock.Any())
+			Expect(repository.FetchWithOptions(nil)).Should(Succeed())
+		})
+	})
+
+	Context("DeepenUntilCommit(commitSHA string, step int) error", func() {
+		It("Should do nothing when the commit is present", func() {
+			repository := NewRepository("", "/tmp/scan")
+			mockCommandBuilder.EXPECT().SetDir("/tmp/scan")
+			mockCommandBuilder.EXPECT().AddCommand("cat-file")
+			mockCommandBuilder.EXPECT().AddArgs([]string{"-e", "abc^{commit}"})
+			mockCommandBuilder.EXPECT().ExecContext(gomock.Any())
+			Expect(repository.DeepenUntilCommit("abc", 10)).Should(Succeed())
+		})
+
+		It("Should deepen with a doubling step until the commit shows up", func() {
+			repository := NewRepository("", "/tmp/scan")
+			mockCommandBuilder.EXPECT().SetDir("/tmp/scan").AnyTimes()
+			gomock.InOrder(
+				mockCommandBuilder.EXPECT().AddCommand("cat-file"),
+				mockCommandBuilder.EXPECT().AddArgs([]string{"-e", "abc^{commit}"}),
+				mockCommandBuilder.EXPECT().ExecContext(gomock.Any()).Return("", badRef()),
+				mockCommandBuilder.EXPECT().AddCommand("rev-parse"),
+				mockCommandBuilder.EXPECT().AddArg("--is-shallow-repository"),
+				mockCommandBuilder.EXPECT().ExecContext(gomock.Any()).Return("true\n", nil),
+				mockCommandBuilder.EXPECT().AddCommand("fetch"),
+				mockCommandBuilder.EXPECT().AddArgs([]string{"--deepen=10"}),
+				mockCommandBuilder.EXPECT().ExecContext(gomock.Any()),
+				mockCommandBuilder.EXPECT().AddCommand("cat-file"),
+				mockCommandBuilder.EXPECT().AddArgs([]string{"-e", "abc^{commit}"}),
+				mockCommandBuilder.EXPECT().ExecContext(gomock.Any()).Return("", badRef()),
+				mockCommandBuilder.EXPECT().AddCommand("rev-parse"),
+				mockCommandBuilder.EXPECT().AddArg("--is-shallow-repository"),
+				mockCommandBuilder.EXPECT().ExecContext(gomock.Any()).Return("true\n", nil),
+				mockCommandBuilder.EXPECT().AddCommand("fetch"),
+				mockCommandBuilder.EXPECT().AddArgs([]string{"--deepen=20"}),
+				mockCommandBuilder.EXPECT().ExecContext(gomock.Any()),
+				mockCommandBuilder.EXPECT().AddCommand("cat-file"),
+				mockCommandBuilder.EXPECT().AddArgs([]string{"-e", "abc^{commit}"}),
+				mockCommandBuilder.EXPECT().ExecContext(gomock.Any()),
+			)
+			Expect(repository.DeepenUntilCommit("abc", 10)).Should(Succeed())
+		})
+
+		It("Should report a commit missing from a complete repository", func() {
+			repository := NewRepository("", "/tmp/scan")
+			mockCommandBuilder.EXPECT().SetDir("/tmp/scan").AnyTimes()
+			gomock.InOrder(
+				mockCommandBuilder.EXPECT().AddCommand("cat-file"),
+				mockCommandBuilder.EXPECT().AddArgs([]string{"-e", "abc^{commit}"}),
+				mockCommandBuilder.EXPECT().ExecContext(gomock.Any()).Return("", badRef()),
+				mockCommandBuilder.EXPECT().AddCommand("rev-parse"),
+				mockCommandBuilder.EXPECT().AddArg("--is-shallow-repository"),
+				mockCommandBuilder.EXPECT().ExecContext(gomock.Any()).Return("false\n", nil),
+			)
+			Expect(repository.DeepenUntilCommit("abc", 10)).Should(MatchError(ErrObjectNotFound))
+		})
+	})
+})
@@ -1,12 +1,18 @@
 package git_wrapper
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"net/url"
 	"os"
+	"path/filepath"
+	"strings"
+	"time"
 )
 
 var commandBuilderFunc = NewCommandBuilder
-var listWorktreeFunc = ListWorktree
+var listWorktreeFunc = ListWorktreeContext
 
 type CloneOptions struct {
 	Username   string
@@ -14,9 +20,35 @@ type CloneOptions struct {
 	Branch     string
 	FilterSpec string
 	Progress   bool
+	// Depth and ShallowSince create a shallow clone truncated to the given
+	// number of commits or to commits newer than the given time.
+	Depth        int
+	ShallowSince time.Time
+	SingleBranch bool
+	NoTags       bool
+	// SSHKey authenticates ssh urls, ignored when an AuthToken is given.
+	SSHKey *SSHKey
 }
 
 func Clone(url string, dest string, option *CloneOptions) (*Repository, error) {
+	return CloneContext(context.Background(), url, dest, option)
+}
+
+// CloneContext clones url into dest. With an AuthToken, url may be given
+// without scheme and is cloned over https, the token being handed to git
+// through a credential helper rather than the url.
+func CloneContext(ctx context.Context, url string, dest string, option *CloneOptions) (*Repository, error) {
+	if option == nil {
+		option = &CloneOptions{}
+	}
+	cloneUrl := url
+	var credentials *Credentials
+	sshKey := option.SSHKey
+	if option.AuthToken != "" {
+		cloneUrl = httpsURL(url)
+		credentials = &Credentials{Username: option.Username, Password: option.AuthToken}
+		sshKey = nil
+	}
 	commandBuilder := commandBuilderFunc()
 	commandBuilder.AddCommand("clone")
 	if option.FilterSpec != "" {
@@ -25,59 +57,182 @@ func Clone(url string, dest string, option *CloneOptions) (*Repository, error) {
 	if option.Branch != "" {
 		commandBuilder.AddArg("--branch=" + option.Branch)
 	}
-	if option.AuthToken != "" {
-		urlWithAuthToken := fmt.Sprintf("https://%s:%s@%s", option.Username, option.AuthToken, url)
-		commandBuilder.AddArg(urlWithAuthToken)
+	if option.Depth > 0 {
+		commandBuilder.AddArg(fmt.Sprintf("--depth=%d", option.Depth))
+	}
+	if !option.ShallowSince.IsZero() {
+		commandBuilder.AddArg("--shallow-since=" + option.ShallowSince.Format(time.RFC3339))
+	}
+	if option.SingleBranch {
+		commandBuilder.AddArg("--single-branch")
 	}
+	if option.NoTags {
+		commandBuilder.AddArg("--no-tags")
+	}
+	if err := credentials.apply(commandBuilder, cloneUrl); err != nil {
+		return nil, err
+	}
+	cleanup, err := sshKey.apply(commandBuilder)
+	if err != nil {
+		return nil, err
+	}
+	defer cleanup()
+	commandBuilder.AddArg(cloneUrl)
 	if dest != "" {
 		commandBuilder.AddArg(dest)
 	}
 	if option.Progress {
-		_, err := commandBuilder.ExecStreamStdout()
+		_, err := commandBuilder.ExecStreamStdoutContext(ctx)
 		if err != nil {
 			return nil, err
 		}
 	} else {
-		_, err := commandBuilder.Exec()
+		_, err := commandBuilder.ExecContext(ctx)
 		if err != nil {
 			return nil, err
 		}
 	}
-	worktrees, _ := listWorktreeFunc(dest)
+	worktrees, _ := listWorktreeFunc(ctx, dest)
 	return &Repository{
-		Url:       url,
-		Dest:      dest,
-		Worktrees: worktrees,
+		Url:         url,
+		Dest:        dest,
+		Worktrees:   worktrees,
+		Credentials: credentials,
+		SSHKey:      sshKey,
 	}, nil
 }
 
+// ErrOriginMismatch is returned by OpenOrClone when dest is a checkout of
+// another repository. It is left untouched, removing it is up to the caller.
+var ErrOriginMismatch = errors.New("git: checkout has another origin")
+
+func OpenOrClone(url string, dest string, option *CloneOptions) (*Repository, error) {
+	return OpenOrCloneContext(context.Background(), url, dest, option)
+}
+
+// OpenOrCloneContext reuses the checkout at dest when its origin is url and
+// clones url into dest when dest is missing or empty. A checkout of another
+// origin fails with ErrOriginMismatch. A dest that exists but isn't the root
+// of a checkout with an origin, for instance a directory inside another
+// repository, is left untouched and reported as well.
+func OpenOrCloneContext(ctx context.Context, url string, dest string, option *CloneOptions) (*Repository, error) {
+	if entries, err := os.ReadDir(dest); err != nil || len(entries) == 0 {
+		return CloneContext(ctx, url, dest, option)
+	}
+	if err := checkRepositoryRoot(ctx, dest); err != nil {
+		return nil, err
+	}
+	repository, err := (&Repository{}).LoadContext(ctx, "", dest)
+	if err != nil {
+		return nil, err
+	}
+	if repository.Url == "" {
+		return nil, fmt.Errorf("repository at %s has no origin remote", dest)
+	}
+	if !sameRepositoryURL(repository.Url, url) {
+		return nil, fmt.Errorf("%w: origin of %s is %s, not %s", ErrOriginMismatch, dest, redact(repository.Url, nil), redact(url, nil))
+	}
+	if option != nil {
+		if option.AuthToken != "" {
+			repository.Credentials = &Credentials{Username: option.Username, Password: option.AuthToken}
+		} else if option.SSHKey != nil {
+			repository.SSHKey = option.SSHKey
+		}
+	}
+	return repository, nil
+}
+
+// checkRepositoryRoot fails unless dest is the top level of a checkout, as git
+// otherwise resolves the repository of a parent directory.
+func checkRepositoryRoot(ctx context.Context, dest string) error {
+	commandBuilder := commandBuilderFunc()
+	commandBuilder.SetDir(dest)
+	commandBuilder.AddCommand("rev-parse")
+	commandBuilder.AddArg("--show-toplevel")
+	output, err := commandBuilder.ExecContext(ctx)
+	if err != nil {
+		return err
+	}
+	topLevel, err := filepath.EvalSymlinks(strings.TrimSpace(output))
+	if err != nil {
+		return err
+	}
+	dir, err := filepath.Abs(dest)
+	if err == nil {
+		dir, err = filepath.EvalSymlinks(dir)
+	}
+	if err != nil {
+		return err
+	}
+	if topLevel != dir {
+		return fmt.Errorf("%s is inside the repository %s", dest, topLevel)
+	}
+	return nil
+}
+
+// sameRepositoryURL compares remote urls ignoring credentials, the scheme
+// CloneOptions lets callers omit, a trailing .git and the case of the host.
+func sameRepositoryURL(a string, b string) bool {
+	return normalizeRepositoryURL(a) == normalizeRepositoryURL(b)
+}
+
+func normalizeRepositoryURL(rawUrl string) string {
+	if !strings.Contains(rawUrl, "://") {
+		if protocolFromURL(rawUrl) == ProtocolSSH {
+			// git@host:path is ssh://git@host/path
+			host, path, _ := strings.Cut(rawUrl, ":")
+			rawUrl = "ssh://" + host + "/" + strings.TrimPrefix(path, "/")
+		} else {
+			rawUrl = httpsURL(rawUrl)
+		}
+	}
+	rawUrl = strings.TrimSuffix(strings.TrimSuffix(rawUrl, "/"), ".git")
+	if parsed, err := url.Parse(rawUrl); err == nil {
+		parsed.Host = strings.ToLower(parsed.Host)
+		if parsed.Scheme != "ssh" {
+			parsed.User = nil
+		}
+		rawUrl = parsed.String()
+	}
+	return rawUrl
+}
+
 func PlainClone(url string, dest string) (*Repository, error) {
+	return PlainCloneContext(context.Background(), url, dest)
+}
+
+// PlainCloneContext clones url into dest. A password embedded in url is passed
+// to git through a credential helper and not stored in the git config.
+func PlainCloneContext(ctx context.Context, url string, dest string) (*Repository, error) {
+	url, credentials := splitURLCredentials(url)
 	commandBuilder := commandBuilderFunc()
 	commandBuilder.AddCommand("clone")
+	if err := credentials.apply(commandBuilder, url); err != nil {
+		return nil, err
+	}
 	commandBuilder.AddArg(url)
 	if dest != "" {
 		commandBuilder.AddArg(dest)
 	}
-	_, err := commandBuilder.Exec()
+	_, err := commandBuilder.ExecContext(ctx)
 	if err != nil {
 		return nil, err
 	}
-	worktrees, _ := listWorktreeFunc(dest)
+	worktrees, _ := listWorktreeFunc(ctx, dest)
 	return &Repository{
-		Url:       url,
-		Dest:      dest,
-		Worktrees: worktrees,
+		Url:         url,
+		Dest:        dest,
+		Worktrees:   worktrees,
+		Credentials: credentials,
 	}, nil
 }
 
 func RemoveRepository(dest string) error {
-	commandBuilder := commandBuilderFunc()
-	commandBuilder.AddCommand("worktree")
-	commandBuilder.AddArg("prune")
-	if dest != "" {
-		commandBuilder.AddArg(dest)
-	}
-	_, err := commandBuilder.Exec()
+	return RemoveRepositoryContext(context.Background(), dest)
+}
+
+func RemoveRepositoryContext(ctx context.Context, dest string) error {
+	err := runWorktreeCommand(ctx, dest, pruneWorktreeArgs(0))
 	if err != nil {
 		return err
 	}
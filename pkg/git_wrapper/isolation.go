@@ -0,0 +1,58 @@
+package git_wrapper
+
+import (
+	"os"
+	"strings"
+)
+
+// isolationConfigs harden git against the repositories it runs on: their
+// hooks and fsmonitor never run, nothing is fetched from local paths, which
+// submodules could point to, and received objects are checked.
+var isolationConfigs = []configEntry{
+	{key: "core.hooksPath", value: os.DevNull},
+	{key: "core.fsmonitor", value: "false"},
+	{key: "protocol.file.allow", value: "never"},
+	{key: "transfer.fsckObjects", value: "true"},
+}
+
+// isolationInheritedEnv are the only variables passed on from the environment
+// of the process to isolated commands. Names are compared in upper case.
+var isolationInheritedEnv = map[string]bool{
+	"PATH":          true,
+	"TMPDIR":        true,
+	"TEMP":          true,
+	"TMP":           true,
+	"LANG":          true,
+	"LC_ALL":        true,
+	"LC_CTYPE":      true,
+	"TZ":            true,
+	"SSL_CERT_FILE": true,
+	"SSL_CERT_DIR":  true,
+	"HTTP_PROXY":    true,
+	"HTTPS_PROXY":   true,
+	"NO_PROXY":      true,
+	"ALL_PROXY":     true,
+	"SSH_AUTH_SOCK": true,
+	// needed by any process on windows
+	"SYSTEMROOT": true,
+	"WINDIR":     true,
+	"COMSPEC":    true,
+	"PATHEXT":    true,
+}
+
+// isolatedEnv returns the scrubbed environment of isolated commands, where
+// neither the system nor the global git config of the host is read.
+func isolatedEnv() []string {
+	env := []string{}
+	for _, variable := range os.Environ() {
+		name, _, _ := strings.Cut(variable, "=")
+		if isolationInheritedEnv[strings.ToUpper(name)] {
+			env = append(env, variable)
+		}
+	}
+	return append(env,
+		"GIT_CONFIG_NOSYSTEM=1",
+		"GIT_CONFIG_GLOBAL="+os.DevNull,
+		"HOME="+os.DevNull,
+	)
+}
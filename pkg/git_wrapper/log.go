@@ -0,0 +1,133 @@
+package git_wrapper
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// logFormat prints one NUL separated field per placeholder. Together with -z,
+// which terminates every commit with a NUL, each commit is exactly
+// logFieldCount fields whatever its message contains.
+const (
+	logFormat     = "%H%x00%P%x00%an%x00%ae%x00%aI%x00%cn%x00%ce%x00%cI%x00%s%x00%b"
+	logFieldCount = 10
+)
+
+type Signature struct {
+	Name  string
+	Email string
+	When  time.Time
+}
+
+type CommitInfo struct {
+	Hash      string
+	Parents   []string
+	Author    Signature
+	Committer Signature
+	Subject   string
+	Body      string
+	dest      string
+}
+
+// Commit returns the commit for use with the diff helpers.
+func (c CommitInfo) Commit() Commit {
+	return NewCommit(c.Hash, c.dest)
+}
+
+type LogOptions struct {
+	// Revision is a revision or range such as "main" or "base..head". It
+	// defaults to HEAD.
+	Revision string
+	// Paths limits the log to commits touching the given pathspecs.
+	Paths []string
+	// Author matches the author name or email as a regular expression.
+	Author   string
+	Since    time.Time
+	Until    time.Time
+	MaxCount int
+}
+
+func (r *Repository) Log(option *LogOptions) ([]CommitInfo, error) {
+	return r.LogContext(context.Background(), option)
+}
+
+// LogContext walks the history from option.Revision, newest first.
+func (r *Repository) LogContext(ctx context.Context, option *LogOptions) ([]CommitInfo, error) {
+	if option == nil {
+		option = &LogOptions{}
+	}
+	revision := option.Revision
+	if revision == "" {
+		revision = "HEAD"
+	}
+	if strings.HasPrefix(revision, "-") {
+		return nil, fmt.Errorf("invalid revision %q", revision)
+	}
+	args := []string{"-z", "--no-color", "--format=" + logFormat}
+	if option.Author != "" {
+		args = append(args, "--author="+option.Author)
+	}
+	if !option.Since.IsZero() {
+		args = append(args, "--since="+option.Since.Format(time.RFC3339))
+	}
+	if !option.Until.IsZero() {
+		args = append(args, "--until="+option.Until.Format(time.RFC3339))
+	}
+	if option.MaxCount > 0 {
+		args = append(args, fmt.Sprintf("--max-count=%d", option.MaxCount))
+	}
+	args = append(args, revision, "--")
+	args = append(args, option.Paths...)
+
+	commandBuilder := commandBuilderFunc()
+	commandBuilder.SetDir(r.Dest)
+	commandBuilder.AddCommand("log")
+	commandBuilder.AddArgs(args)
+	output, err := commandBuilder.ExecContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+	commits, err := ParseLog(output)
+	if err != nil {
+		return nil, err
+	}
+	for i := range commits {
+		commits[i].dest = r.Dest
+	}
+	return commits, nil
+}
+
+// ParseLog parses `git log -z` output produced with logFormat.
+func ParseLog(output string) ([]CommitInfo, error) {
+	commits := []CommitInfo{}
+	output = strings.TrimSuffix(output, "\x00")
+	if output == "" {
+		return commits, nil
+	}
+	fields := strings.Split(output, "\x00")
+	if len(fields)%logFieldCount != 0 {
+		return nil, fmt.Errorf("malformed log output: %d fields", len(fields))
+	}
+	for i := 0; i < len(fields); i += logFieldCount {
+		record := fields[i : i+logFieldCount]
+		authorTime, err := time.Parse(time.RFC3339, record[4])
+		if err != nil {
+			return nil, fmt.Errorf("malformed author date of %s: %w", record[0], err)
+		}
+		committerTime, err := time.Parse(time.RFC3339, record[7])
+		if err != nil {
+			return nil, fmt.Errorf("malformed committer date of %s: %w", record[0], err)
+		}
+		commits = append(commits, CommitInfo{
+			Hash:      record[0],
+			Parents:   strings.Fields(record[1]),
+			Author:    Signature{Name: record[2], Email: record[3], When: authorTime},
+			Committer: Signature{Name: record[5], Email: record[6], When: committerTime},
+			Subject:   record[8],
+			Body:      strings.TrimRight(record[9], "\n"),
+		})
+	}
+	return commits, nil
+}
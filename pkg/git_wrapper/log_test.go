@@ -0,0 +1,83 @@
+package git_wrapper
+
+import (
+	mock_git_wrapper "operarius/mock/pkg/git_wrapper"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+const sampleLog = "3391ff34654500919340e2f26d8e7fe355bb1703\x0093ebb54372ffd4ca1942b9c23df4448ef2d3b04f\x00A B\x00a@b\x002026-10-18T05:26:07+02:00\x00C D\x00c@d\x002026-10-18T05:30:00+00:00\x00subj\x00body line1\nbody line2\n\x00" +
+	"93ebb54372ffd4ca1942b9c23df4448ef2d3b04f\x00\x00a\x00a@b\x002026-10-18T05:25:24+00:00\x00a\x00a@b\x002026-10-18T05:25:24+00:00\x00first\x00\x00"
+
+var _ = Describe("Log unit test", func() {
+	var mockCtrl *gomock.Controller
+	var mockCommandBuilder *mock_git_wrapper.MockICommandBuilder
+	old := commandBuilderFunc
+	BeforeEach(func() {
+		mockCtrl = gomock.NewController(GinkgoT())
+		mockCommandBuilder = mock_git_wrapper.NewMockICommandBuilder(mockCtrl)
+		commandBuilderFunc = func() ICommandBuilder {
+			return mockCommandBuilder
+		}
+	})
+	AfterEach(func() {
+		defer func() { commandBuilderFunc = old }()
+	})
+
+	Context("Log(option *LogOptions) ([]CommitInfo, error)", func() {
+		It("Should walk HEAD without filters", func() {
+			repository := NewRepository("", "/tmp/scan")
+			mockCommandBuilder.EXPECT().SetDir("/tmp/scan")
+			mockCommandBuilder.EXPECT().AddCommand("log")
+			mockCommandBuilder.EXPECT().AddArgs([]string{"-z", "--no-color", "--format=" + logFormat, "HEAD", "--"})
+			mockCommandBuilder.EXPECT().ExecContext(gomock.Any()).Return(sampleLog, nil)
+			commits, err := repository.Log(nil)
+			Expect(err).Should(BeNil())
+			Expect(commits).Should(HaveLen(2))
+			Expect(commits[0].Hash).Should(Equal("3391ff34654500919340e2f26d8e7fe355bb1703"))
+			Expect(commits[0].Parents).Should(Equal([]string{"93ebb54372ffd4ca1942b9c23df4448ef2d3b04f"}))
+			Expect(commits[0].Author.Name).Should(Equal("A B"))
+			Expect(commits[0].Author.When.Equal(time.Date(2026, 10, 18, 3, 26, 7, 0, time.UTC))).Should(BeTrue())
+			Expect(commits[0].Committer.Email).Should(Equal("c@d"))
+			Expect(commits[0].Subject).Should(Equal("subj"))
+			Expect(commits[0].Body).Should(Equal("body line1\nbody line2"))
+			Expect(commits[0].Commit()).Should(Equal(NewCommit("3391ff34654500919340e2f26d8e7fe355bb1703", "/tmp/scan")))
+			Expect(commits[1].Parents).Should(BeEmpty())
+			Expect(commits[1].Body).Should(Equal(""))
+		})
+
+		It("Should pass the range and filters", func() {
+			repository := NewRepository("", "/tmp/scan")
+			mockCommandBuilder.EXPECT().SetDir("/tmp/scan")
+			mockCommandBuilder.EXPECT().AddCommand("log")
+			mockCommandBuilder.EXPECT().AddArgs([]string{"-z", "--no-color", "--format=" + logFormat,
+				"--author=a@b", "--since=2026-01-01T00:00:00Z", "--until=2026-02-01T00:00:00Z", "--max-count=5",
+				"base..head", "--", "src/"})
+			mockCommandBuilder.EXPECT().ExecContext(gomock.Any()).Return("", nil)
+			commits, err := repository.Log(&LogOptions{
+				Revision: "base..head",
+				Paths:    []string{"src/"},
+				Author:   "a@b",
+				Since:    time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC),
+				Until:    time.Date(2026, 2, 1, 0, 0, 0, 0, time.UTC),
+				MaxCount: 5,
+			})
+			Expect(err).Should(BeNil())
+			Expect(commits).Should(BeEmpty())
+		})
+
+		It("Should reject revisions that look like options", func() {
+			repository := NewRepository("", "/tmp/scan")
+			_, err := repository.Log(&LogOptions{Revision: "--output=/tmp/x"})
+			Expect(err).ShouldNot(BeNil())
+		})
+
+		It("Should reject truncated output", func() {
+			_, err := ParseLog("93ebb54372ffd4ca1942b9c23df4448ef2d3b04f\x00\x00a")
+			Expect(err).ShouldNot(BeNil())
+		})
+	})
+})
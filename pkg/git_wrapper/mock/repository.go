@@ -5,8 +5,10 @@
 package mock
 
 import (
+	context "context"
 	git_wrapper "operarius/pkg/git_wrapper"
 	reflect "reflect"
+	time "time"
 
 	gomock "github.com/golang/mock/gomock"
 	logger "github.com/guardrailsio/go-scan-helper/logger"
@@ -50,6 +52,51 @@ func (mr *MockIRepositoryMockRecorder) AddWorktree(path, commitSHA interface{})
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "AddWorktree", reflect.TypeOf((*MockIRepository)(nil).AddWorktree), path, commitSHA)
 }
 
+// AddWorktreeContext mocks base method.
+func (m *MockIRepository) AddWorktreeContext(ctx context.Context, path, commitSHA string) (*git_wrapper.Worktree, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "AddWorktreeContext", ctx, path, commitSHA)
+	ret0, _ := ret[0].(*git_wrapper.Worktree)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// AddWorktreeContext indicates an expected call of AddWorktreeContext.
+func (mr *MockIRepositoryMockRecorder) AddWorktreeContext(ctx, path, commitSHA interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "AddWorktreeContext", reflect.TypeOf((*MockIRepository)(nil).AddWorktreeContext), ctx, path, commitSHA)
+}
+
+// AddWorktreeWithOptions mocks base method.
+func (m *MockIRepository) AddWorktreeWithOptions(path, commitSHA string, option *git_wrapper.WorktreeOptions) (*git_wrapper.Worktree, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "AddWorktreeWithOptions", path, commitSHA, option)
+	ret0, _ := ret[0].(*git_wrapper.Worktree)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// AddWorktreeWithOptions indicates an expected call of AddWorktreeWithOptions.
+func (mr *MockIRepositoryMockRecorder) AddWorktreeWithOptions(path, commitSHA, option interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "AddWorktreeWithOptions", reflect.TypeOf((*MockIRepository)(nil).AddWorktreeWithOptions), path, commitSHA, option)
+}
+
+// AddWorktreeWithOptionsContext mocks base method.
+func (m *MockIRepository) AddWorktreeWithOptionsContext(ctx context.Context, path, commitSHA string, option *git_wrapper.WorktreeOptions) (*git_wrapper.Worktree, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "AddWorktreeWithOptionsContext", ctx, path, commitSHA, option)
+	ret0, _ := ret[0].(*git_wrapper.Worktree)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// AddWorktreeWithOptionsContext indicates an expected call of AddWorktreeWithOptionsContext.
+func (mr *MockIRepositoryMockRecorder) AddWorktreeWithOptionsContext(ctx, path, commitSHA, option interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "AddWorktreeWithOptionsContext", reflect.TypeOf((*MockIRepository)(nil).AddWorktreeWithOptionsContext), ctx, path, commitSHA, option)
+}
+
 // Branches mocks base method.
 func (m *MockIRepository) Branches() ([]string, error) {
 	m.ctrl.T.Helper()
@@ -65,6 +112,21 @@ func (mr *MockIRepositoryMockRecorder) Branches() *gomock.Call {
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Branches", reflect.TypeOf((*MockIRepository)(nil).Branches))
 }
 
+// BranchesContext mocks base method.
+func (m *MockIRepository) BranchesContext(ctx context.Context) ([]string, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "BranchesContext", ctx)
+	ret0, _ := ret[0].([]string)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// BranchesContext indicates an expected call of BranchesContext.
+func (mr *MockIRepositoryMockRecorder) BranchesContext(ctx interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "BranchesContext", reflect.TypeOf((*MockIRepository)(nil).BranchesContext), ctx)
+}
+
 // CheckoutBranch mocks base method.
 func (m *MockIRepository) CheckoutBranch(branch string) (*git_wrapper.Branch, error) {
 	m.ctrl.T.Helper()
@@ -80,6 +142,21 @@ func (mr *MockIRepositoryMockRecorder) CheckoutBranch(branch interface{}) *gomoc
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CheckoutBranch", reflect.TypeOf((*MockIRepository)(nil).CheckoutBranch), branch)
 }
 
+// CheckoutBranchContext mocks base method.
+func (m *MockIRepository) CheckoutBranchContext(ctx context.Context, branch string) (*git_wrapper.Branch, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CheckoutBranchContext", ctx, branch)
+	ret0, _ := ret[0].(*git_wrapper.Branch)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// CheckoutBranchContext indicates an expected call of CheckoutBranchContext.
+func (mr *MockIRepositoryMockRecorder) CheckoutBranchContext(ctx, branch interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CheckoutBranchContext", reflect.TypeOf((*MockIRepository)(nil).CheckoutBranchContext), ctx, branch)
+}
+
 // CheckoutCommit mocks base method.
 func (m *MockIRepository) CheckoutCommit(commit string) (*git_wrapper.Commit, error) {
 	m.ctrl.T.Helper()
@@ -95,6 +172,93 @@ func (mr *MockIRepositoryMockRecorder) CheckoutCommit(commit interface{}) *gomoc
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CheckoutCommit", reflect.TypeOf((*MockIRepository)(nil).CheckoutCommit), commit)
 }
 
+// CheckoutCommitContext mocks base method.
+func (m *MockIRepository) CheckoutCommitContext(ctx context.Context, commit string) (*git_wrapper.Commit, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CheckoutCommitContext", ctx, commit)
+	ret0, _ := ret[0].(*git_wrapper.Commit)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// CheckoutCommitContext indicates an expected call of CheckoutCommitContext.
+func (mr *MockIRepositoryMockRecorder) CheckoutCommitContext(ctx, commit interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CheckoutCommitContext", reflect.TypeOf((*MockIRepository)(nil).CheckoutCommitContext), ctx, commit)
+}
+
+// Close mocks base method.
+func (m *MockIRepository) Close() error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Close")
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// Close indicates an expected call of Close.
+func (mr *MockIRepositoryMockRecorder) Close() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Close", reflect.TypeOf((*MockIRepository)(nil).Close))
+}
+
+// CountObjects mocks base method.
+func (m *MockIRepository) CountObjects() (*git_wrapper.ObjectCount, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CountObjects")
+	ret0, _ := ret[0].(*git_wrapper.ObjectCount)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// CountObjects indicates an expected call of CountObjects.
+func (mr *MockIRepositoryMockRecorder) CountObjects() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CountObjects", reflect.TypeOf((*MockIRepository)(nil).CountObjects))
+}
+
+// CountObjectsContext mocks base method.
+func (m *MockIRepository) CountObjectsContext(ctx context.Context) (*git_wrapper.ObjectCount, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "CountObjectsContext", ctx)
+	ret0, _ := ret[0].(*git_wrapper.ObjectCount)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// CountObjectsContext indicates an expected call of CountObjectsContext.
+func (mr *MockIRepositoryMockRecorder) CountObjectsContext(ctx interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "CountObjectsContext", reflect.TypeOf((*MockIRepository)(nil).CountObjectsContext), ctx)
+}
+
+// DeepenUntilCommit mocks base method.
+func (m *MockIRepository) DeepenUntilCommit(commitSHA string, step int) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DeepenUntilCommit", commitSHA, step)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// DeepenUntilCommit indicates an expected call of DeepenUntilCommit.
+func (mr *MockIRepositoryMockRecorder) DeepenUntilCommit(commitSHA, step interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeepenUntilCommit", reflect.TypeOf((*MockIRepository)(nil).DeepenUntilCommit), commitSHA, step)
+}
+
+// DeepenUntilCommitContext mocks base method.
+func (m *MockIRepository) DeepenUntilCommitContext(ctx context.Context, commitSHA string, step int) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "DeepenUntilCommitContext", ctx, commitSHA, step)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// DeepenUntilCommitContext indicates an expected call of DeepenUntilCommitContext.
+func (mr *MockIRepositoryMockRecorder) DeepenUntilCommitContext(ctx, commitSHA, step interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "DeepenUntilCommitContext", reflect.TypeOf((*MockIRepository)(nil).DeepenUntilCommitContext), ctx, commitSHA, step)
+}
+
 // Fetch mocks base method.
 func (m *MockIRepository) Fetch() error {
 	m.ctrl.T.Helper()
@@ -109,6 +273,106 @@ func (mr *MockIRepositoryMockRecorder) Fetch() *gomock.Call {
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Fetch", reflect.TypeOf((*MockIRepository)(nil).Fetch))
 }
 
+// FetchCommit mocks base method.
+func (m *MockIRepository) FetchCommit(commitSHA string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "FetchCommit", commitSHA)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// FetchCommit indicates an expected call of FetchCommit.
+func (mr *MockIRepositoryMockRecorder) FetchCommit(commitSHA interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "FetchCommit", reflect.TypeOf((*MockIRepository)(nil).FetchCommit), commitSHA)
+}
+
+// FetchCommitContext mocks base method.
+func (m *MockIRepository) FetchCommitContext(ctx context.Context, commitSHA string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "FetchCommitContext", ctx, commitSHA)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// FetchCommitContext indicates an expected call of FetchCommitContext.
+func (mr *MockIRepositoryMockRecorder) FetchCommitContext(ctx, commitSHA interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "FetchCommitContext", reflect.TypeOf((*MockIRepository)(nil).FetchCommitContext), ctx, commitSHA)
+}
+
+// FetchContext mocks base method.
+func (m *MockIRepository) FetchContext(ctx context.Context) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "FetchContext", ctx)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// FetchContext indicates an expected call of FetchContext.
+func (mr *MockIRepositoryMockRecorder) FetchContext(ctx interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "FetchContext", reflect.TypeOf((*MockIRepository)(nil).FetchContext), ctx)
+}
+
+// FetchPullRequest mocks base method.
+func (m *MockIRepository) FetchPullRequest(provider git_wrapper.Provider, number int) (*git_wrapper.PullRequestRefs, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "FetchPullRequest", provider, number)
+	ret0, _ := ret[0].(*git_wrapper.PullRequestRefs)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// FetchPullRequest indicates an expected call of FetchPullRequest.
+func (mr *MockIRepositoryMockRecorder) FetchPullRequest(provider, number interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "FetchPullRequest", reflect.TypeOf((*MockIRepository)(nil).FetchPullRequest), provider, number)
+}
+
+// FetchPullRequestContext mocks base method.
+func (m *MockIRepository) FetchPullRequestContext(ctx context.Context, provider git_wrapper.Provider, number int) (*git_wrapper.PullRequestRefs, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "FetchPullRequestContext", ctx, provider, number)
+	ret0, _ := ret[0].(*git_wrapper.PullRequestRefs)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// FetchPullRequestContext indicates an expected call of FetchPullRequestContext.
+func (mr *MockIRepositoryMockRecorder) FetchPullRequestContext(ctx, provider, number interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "FetchPullRequestContext", reflect.TypeOf((*MockIRepository)(nil).FetchPullRequestContext), ctx, provider, number)
+}
+
+// FetchWithOptions mocks base method.
+func (m *MockIRepository) FetchWithOptions(option *git_wrapper.FetchOptions) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "FetchWithOptions", option)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// FetchWithOptions indicates an expected call of FetchWithOptions.
+func (mr *MockIRepositoryMockRecorder) FetchWithOptions(option interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "FetchWithOptions", reflect.TypeOf((*MockIRepository)(nil).FetchWithOptions), option)
+}
+
+// FetchWithOptionsContext mocks base method.
+func (m *MockIRepository) FetchWithOptionsContext(ctx context.Context, option *git_wrapper.FetchOptions) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "FetchWithOptionsContext", ctx, option)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// FetchWithOptionsContext indicates an expected call of FetchWithOptionsContext.
+func (mr *MockIRepositoryMockRecorder) FetchWithOptionsContext(ctx, option interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "FetchWithOptionsContext", reflect.TypeOf((*MockIRepository)(nil).FetchWithOptionsContext), ctx, option)
+}
+
 // FlushWorktree mocks base method.
 func (m *MockIRepository) FlushWorktree() error {
 	m.ctrl.T.Helper()
@@ -123,6 +387,48 @@ func (mr *MockIRepositoryMockRecorder) FlushWorktree() *gomock.Call {
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "FlushWorktree", reflect.TypeOf((*MockIRepository)(nil).FlushWorktree))
 }
 
+// FlushWorktreeContext mocks base method.
+func (m *MockIRepository) FlushWorktreeContext(ctx context.Context) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "FlushWorktreeContext", ctx)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// FlushWorktreeContext indicates an expected call of FlushWorktreeContext.
+func (mr *MockIRepositoryMockRecorder) FlushWorktreeContext(ctx interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "FlushWorktreeContext", reflect.TypeOf((*MockIRepository)(nil).FlushWorktreeContext), ctx)
+}
+
+// ForEachBlob mocks base method.
+func (m *MockIRepository) ForEachBlob(option *git_wrapper.BlobIteratorOptions, fn func(git_wrapper.HistoryBlob) error) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ForEachBlob", option, fn)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// ForEachBlob indicates an expected call of ForEachBlob.
+func (mr *MockIRepositoryMockRecorder) ForEachBlob(option, fn interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ForEachBlob", reflect.TypeOf((*MockIRepository)(nil).ForEachBlob), option, fn)
+}
+
+// ForEachBlobContext mocks base method.
+func (m *MockIRepository) ForEachBlobContext(ctx context.Context, option *git_wrapper.BlobIteratorOptions, fn func(git_wrapper.HistoryBlob) error) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ForEachBlobContext", ctx, option, fn)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// ForEachBlobContext indicates an expected call of ForEachBlobContext.
+func (mr *MockIRepositoryMockRecorder) ForEachBlobContext(ctx, option, fn interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ForEachBlobContext", reflect.TypeOf((*MockIRepository)(nil).ForEachBlobContext), ctx, option, fn)
+}
+
 // GetDestination mocks base method.
 func (m *MockIRepository) GetDestination() string {
 	m.ctrl.T.Helper()
@@ -137,12 +443,187 @@ func (mr *MockIRepositoryMockRecorder) GetDestination() *gomock.Call {
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetDestination", reflect.TypeOf((*MockIRepository)(nil).GetDestination))
 }
 
+// GetDiffBetweenCommits mocks base method.
+func (m *MockIRepository) GetDiffBetweenCommits(commit, target string) ([]git_wrapper.FileDiff, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetDiffBetweenCommits", commit, target)
+	ret0, _ := ret[0].([]git_wrapper.FileDiff)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetDiffBetweenCommits indicates an expected call of GetDiffBetweenCommits.
+func (mr *MockIRepositoryMockRecorder) GetDiffBetweenCommits(commit, target interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetDiffBetweenCommits", reflect.TypeOf((*MockIRepository)(nil).GetDiffBetweenCommits), commit, target)
+}
+
+// GetDiffBetweenCommitsContext mocks base method.
+func (m *MockIRepository) GetDiffBetweenCommitsContext(ctx context.Context, commit, target string) ([]git_wrapper.FileDiff, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetDiffBetweenCommitsContext", ctx, commit, target)
+	ret0, _ := ret[0].([]git_wrapper.FileDiff)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetDiffBetweenCommitsContext indicates an expected call of GetDiffBetweenCommitsContext.
+func (mr *MockIRepositoryMockRecorder) GetDiffBetweenCommitsContext(ctx, commit, target interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetDiffBetweenCommitsContext", reflect.TypeOf((*MockIRepository)(nil).GetDiffBetweenCommitsContext), ctx, commit, target)
+}
+
+// GetDiffContentBetweenCommits mocks base method.
+func (m *MockIRepository) GetDiffContentBetweenCommits(commit, target string) (string, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetDiffContentBetweenCommits", commit, target)
+	ret0, _ := ret[0].(string)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetDiffContentBetweenCommits indicates an expected call of GetDiffContentBetweenCommits.
+func (mr *MockIRepositoryMockRecorder) GetDiffContentBetweenCommits(commit, target interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetDiffContentBetweenCommits", reflect.TypeOf((*MockIRepository)(nil).GetDiffContentBetweenCommits), commit, target)
+}
+
+// GetDiffContentBetweenCommitsContext mocks base method.
+func (m *MockIRepository) GetDiffContentBetweenCommitsContext(ctx context.Context, commit, target string) (string, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetDiffContentBetweenCommitsContext", ctx, commit, target)
+	ret0, _ := ret[0].(string)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// GetDiffContentBetweenCommitsContext indicates an expected call of GetDiffContentBetweenCommitsContext.
+func (mr *MockIRepositoryMockRecorder) GetDiffContentBetweenCommitsContext(ctx, commit, target interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetDiffContentBetweenCommitsContext", reflect.TypeOf((*MockIRepository)(nil).GetDiffContentBetweenCommitsContext), ctx, commit, target)
+}
+
+// GetWorktrees mocks base method.
+func (m *MockIRepository) GetWorktrees() []git_wrapper.Worktree {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "GetWorktrees")
+	ret0, _ := ret[0].([]git_wrapper.Worktree)
+	return ret0
+}
+
+// GetWorktrees indicates an expected call of GetWorktrees.
+func (mr *MockIRepositoryMockRecorder) GetWorktrees() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "GetWorktrees", reflect.TypeOf((*MockIRepository)(nil).GetWorktrees))
+}
+
+// HasCommit mocks base method.
+func (m *MockIRepository) HasCommit(commitSHA string) (bool, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "HasCommit", commitSHA)
+	ret0, _ := ret[0].(bool)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// HasCommit indicates an expected call of HasCommit.
+func (mr *MockIRepositoryMockRecorder) HasCommit(commitSHA interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "HasCommit", reflect.TypeOf((*MockIRepository)(nil).HasCommit), commitSHA)
+}
+
+// HasCommitContext mocks base method.
+func (m *MockIRepository) HasCommitContext(ctx context.Context, commitSHA string) (bool, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "HasCommitContext", ctx, commitSHA)
+	ret0, _ := ret[0].(bool)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// HasCommitContext indicates an expected call of HasCommitContext.
+func (mr *MockIRepositoryMockRecorder) HasCommitContext(ctx, commitSHA interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "HasCommitContext", reflect.TypeOf((*MockIRepository)(nil).HasCommitContext), ctx, commitSHA)
+}
+
+// IsShallow mocks base method.
+func (m *MockIRepository) IsShallow() (bool, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "IsShallow")
+	ret0, _ := ret[0].(bool)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// IsShallow indicates an expected call of IsShallow.
+func (mr *MockIRepositoryMockRecorder) IsShallow() *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "IsShallow", reflect.TypeOf((*MockIRepository)(nil).IsShallow))
+}
+
+// IsShallowContext mocks base method.
+func (m *MockIRepository) IsShallowContext(ctx context.Context) (bool, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "IsShallowContext", ctx)
+	ret0, _ := ret[0].(bool)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// IsShallowContext indicates an expected call of IsShallowContext.
+func (mr *MockIRepositoryMockRecorder) IsShallowContext(ctx interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "IsShallowContext", reflect.TypeOf((*MockIRepository)(nil).IsShallowContext), ctx)
+}
+
+// ListTree mocks base method.
+func (m *MockIRepository) ListTree(rev string, paths ...string) ([]git_wrapper.TreeEntry, error) {
+	m.ctrl.T.Helper()
+	varargs := []interface{}{rev}
+	for _, a := range paths {
+		varargs = append(varargs, a)
+	}
+	ret := m.ctrl.Call(m, "ListTree", varargs...)
+	ret0, _ := ret[0].([]git_wrapper.TreeEntry)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ListTree indicates an expected call of ListTree.
+func (mr *MockIRepositoryMockRecorder) ListTree(rev interface{}, paths ...interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	varargs := append([]interface{}{rev}, paths...)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListTree", reflect.TypeOf((*MockIRepository)(nil).ListTree), varargs...)
+}
+
+// ListTreeContext mocks base method.
+func (m *MockIRepository) ListTreeContext(ctx context.Context, rev string, paths ...string) ([]git_wrapper.TreeEntry, error) {
+	m.ctrl.T.Helper()
+	varargs := []interface{}{ctx, rev}
+	for _, a := range paths {
+		varargs = append(varargs, a)
+	}
+	ret := m.ctrl.Call(m, "ListTreeContext", varargs...)
+	ret0, _ := ret[0].([]git_wrapper.TreeEntry)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ListTreeContext indicates an expected call of ListTreeContext.
+func (mr *MockIRepositoryMockRecorder) ListTreeContext(ctx, rev interface{}, paths ...interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	varargs := append([]interface{}{ctx, rev}, paths...)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ListTreeContext", reflect.TypeOf((*MockIRepository)(nil).ListTreeContext), varargs...)
+}
+
 // Load mocks base method.
-func (m *MockIRepository) Load(url, dest string) *git_wrapper.Repository {
+func (m *MockIRepository) Load(url, dest string) (*git_wrapper.Repository, error) {
 	m.ctrl.T.Helper()
 	ret := m.ctrl.Call(m, "Load", url, dest)
 	ret0, _ := ret[0].(*git_wrapper.Repository)
-	return ret0
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
 }
 
 // Load indicates an expected call of Load.
@@ -151,6 +632,165 @@ func (mr *MockIRepositoryMockRecorder) Load(url, dest interface{}) *gomock.Call
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Load", reflect.TypeOf((*MockIRepository)(nil).Load), url, dest)
 }
 
+// LoadContext mocks base method.
+func (m *MockIRepository) LoadContext(ctx context.Context, url, dest string) (*git_wrapper.Repository, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "LoadContext", ctx, url, dest)
+	ret0, _ := ret[0].(*git_wrapper.Repository)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// LoadContext indicates an expected call of LoadContext.
+func (mr *MockIRepositoryMockRecorder) LoadContext(ctx, url, dest interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "LoadContext", reflect.TypeOf((*MockIRepository)(nil).LoadContext), ctx, url, dest)
+}
+
+// LockWorktree mocks base method.
+func (m *MockIRepository) LockWorktree(worktreeDest, reason string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "LockWorktree", worktreeDest, reason)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// LockWorktree indicates an expected call of LockWorktree.
+func (mr *MockIRepositoryMockRecorder) LockWorktree(worktreeDest, reason interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "LockWorktree", reflect.TypeOf((*MockIRepository)(nil).LockWorktree), worktreeDest, reason)
+}
+
+// LockWorktreeContext mocks base method.
+func (m *MockIRepository) LockWorktreeContext(ctx context.Context, worktreeDest, reason string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "LockWorktreeContext", ctx, worktreeDest, reason)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// LockWorktreeContext indicates an expected call of LockWorktreeContext.
+func (mr *MockIRepositoryMockRecorder) LockWorktreeContext(ctx, worktreeDest, reason interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "LockWorktreeContext", reflect.TypeOf((*MockIRepository)(nil).LockWorktreeContext), ctx, worktreeDest, reason)
+}
+
+// Log mocks base method.
+func (m *MockIRepository) Log(option *git_wrapper.LogOptions) ([]git_wrapper.CommitInfo, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "Log", option)
+	ret0, _ := ret[0].([]git_wrapper.CommitInfo)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// Log indicates an expected call of Log.
+func (mr *MockIRepositoryMockRecorder) Log(option interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Log", reflect.TypeOf((*MockIRepository)(nil).Log), option)
+}
+
+// LogContext mocks base method.
+func (m *MockIRepository) LogContext(ctx context.Context, option *git_wrapper.LogOptions) ([]git_wrapper.CommitInfo, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "LogContext", ctx, option)
+	ret0, _ := ret[0].([]git_wrapper.CommitInfo)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// LogContext indicates an expected call of LogContext.
+func (mr *MockIRepositoryMockRecorder) LogContext(ctx, option interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "LogContext", reflect.TypeOf((*MockIRepository)(nil).LogContext), ctx, option)
+}
+
+// MoveWorktree mocks base method.
+func (m *MockIRepository) MoveWorktree(worktreeDest, newPath string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "MoveWorktree", worktreeDest, newPath)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// MoveWorktree indicates an expected call of MoveWorktree.
+func (mr *MockIRepositoryMockRecorder) MoveWorktree(worktreeDest, newPath interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "MoveWorktree", reflect.TypeOf((*MockIRepository)(nil).MoveWorktree), worktreeDest, newPath)
+}
+
+// MoveWorktreeContext mocks base method.
+func (m *MockIRepository) MoveWorktreeContext(ctx context.Context, worktreeDest, newPath string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "MoveWorktreeContext", ctx, worktreeDest, newPath)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// MoveWorktreeContext indicates an expected call of MoveWorktreeContext.
+func (mr *MockIRepositoryMockRecorder) MoveWorktreeContext(ctx, worktreeDest, newPath interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "MoveWorktreeContext", reflect.TypeOf((*MockIRepository)(nil).MoveWorktreeContext), ctx, worktreeDest, newPath)
+}
+
+// PathExists mocks base method.
+func (m *MockIRepository) PathExists(rev, path string) (bool, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "PathExists", rev, path)
+	ret0, _ := ret[0].(bool)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// PathExists indicates an expected call of PathExists.
+func (mr *MockIRepositoryMockRecorder) PathExists(rev, path interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "PathExists", reflect.TypeOf((*MockIRepository)(nil).PathExists), rev, path)
+}
+
+// PathExistsContext mocks base method.
+func (m *MockIRepository) PathExistsContext(ctx context.Context, rev, path string) (bool, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "PathExistsContext", ctx, rev, path)
+	ret0, _ := ret[0].(bool)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// PathExistsContext indicates an expected call of PathExistsContext.
+func (mr *MockIRepositoryMockRecorder) PathExistsContext(ctx, rev, path interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "PathExistsContext", reflect.TypeOf((*MockIRepository)(nil).PathExistsContext), ctx, rev, path)
+}
+
+// PruneWorktrees mocks base method.
+func (m *MockIRepository) PruneWorktrees(expire time.Duration) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "PruneWorktrees", expire)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// PruneWorktrees indicates an expected call of PruneWorktrees.
+func (mr *MockIRepositoryMockRecorder) PruneWorktrees(expire interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "PruneWorktrees", reflect.TypeOf((*MockIRepository)(nil).PruneWorktrees), expire)
+}
+
+// PruneWorktreesContext mocks base method.
+func (m *MockIRepository) PruneWorktreesContext(ctx context.Context, expire time.Duration) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "PruneWorktreesContext", ctx, expire)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// PruneWorktreesContext indicates an expected call of PruneWorktreesContext.
+func (mr *MockIRepositoryMockRecorder) PruneWorktreesContext(ctx, expire interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "PruneWorktreesContext", reflect.TypeOf((*MockIRepository)(nil).PruneWorktreesContext), ctx, expire)
+}
+
 // Pull mocks base method.
 func (m *MockIRepository) Pull() error {
 	m.ctrl.T.Helper()
@@ -165,6 +805,50 @@ func (mr *MockIRepositoryMockRecorder) Pull() *gomock.Call {
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "Pull", reflect.TypeOf((*MockIRepository)(nil).Pull))
 }
 
+// PullContext mocks base method.
+func (m *MockIRepository) PullContext(ctx context.Context) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "PullContext", ctx)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// PullContext indicates an expected call of PullContext.
+func (mr *MockIRepositoryMockRecorder) PullContext(ctx interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "PullContext", reflect.TypeOf((*MockIRepository)(nil).PullContext), ctx)
+}
+
+// ReadBlob mocks base method.
+func (m *MockIRepository) ReadBlob(rev, path string) ([]byte, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ReadBlob", rev, path)
+	ret0, _ := ret[0].([]byte)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ReadBlob indicates an expected call of ReadBlob.
+func (mr *MockIRepositoryMockRecorder) ReadBlob(rev, path interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ReadBlob", reflect.TypeOf((*MockIRepository)(nil).ReadBlob), rev, path)
+}
+
+// ReadBlobContext mocks base method.
+func (m *MockIRepository) ReadBlobContext(ctx context.Context, rev, path string) ([]byte, error) {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "ReadBlobContext", ctx, rev, path)
+	ret0, _ := ret[0].([]byte)
+	ret1, _ := ret[1].(error)
+	return ret0, ret1
+}
+
+// ReadBlobContext indicates an expected call of ReadBlobContext.
+func (mr *MockIRepositoryMockRecorder) ReadBlobContext(ctx, rev, path interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "ReadBlobContext", reflect.TypeOf((*MockIRepository)(nil).ReadBlobContext), ctx, rev, path)
+}
+
 // RemoveRepository mocks base method.
 func (m *MockIRepository) RemoveRepository() error {
 	m.ctrl.T.Helper()
@@ -179,6 +863,113 @@ func (mr *MockIRepositoryMockRecorder) RemoveRepository() *gomock.Call {
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RemoveRepository", reflect.TypeOf((*MockIRepository)(nil).RemoveRepository))
 }
 
+// RemoveRepositoryContext mocks base method.
+func (m *MockIRepository) RemoveRepositoryContext(ctx context.Context) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "RemoveRepositoryContext", ctx)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// RemoveRepositoryContext indicates an expected call of RemoveRepositoryContext.
+func (mr *MockIRepositoryMockRecorder) RemoveRepositoryContext(ctx interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RemoveRepositoryContext", reflect.TypeOf((*MockIRepository)(nil).RemoveRepositoryContext), ctx)
+}
+
+// RemoveWorktree mocks base method.
+func (m *MockIRepository) RemoveWorktree(worktreeDest string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "RemoveWorktree", worktreeDest)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// RemoveWorktree indicates an expected call of RemoveWorktree.
+func (mr *MockIRepositoryMockRecorder) RemoveWorktree(worktreeDest interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RemoveWorktree", reflect.TypeOf((*MockIRepository)(nil).RemoveWorktree), worktreeDest)
+}
+
+// RemoveWorktreeContext mocks base method.
+func (m *MockIRepository) RemoveWorktreeContext(ctx context.Context, worktreeDest string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "RemoveWorktreeContext", ctx, worktreeDest)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// RemoveWorktreeContext indicates an expected call of RemoveWorktreeContext.
+func (mr *MockIRepositoryMockRecorder) RemoveWorktreeContext(ctx, worktreeDest interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RemoveWorktreeContext", reflect.TypeOf((*MockIRepository)(nil).RemoveWorktreeContext), ctx, worktreeDest)
+}
+
+// RemoveWorktreeForce mocks base method.
+func (m *MockIRepository) RemoveWorktreeForce(worktreeDest string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "RemoveWorktreeForce", worktreeDest)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// RemoveWorktreeForce indicates an expected call of RemoveWorktreeForce.
+func (mr *MockIRepositoryMockRecorder) RemoveWorktreeForce(worktreeDest interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RemoveWorktreeForce", reflect.TypeOf((*MockIRepository)(nil).RemoveWorktreeForce), worktreeDest)
+}
+
+// RemoveWorktreeForceContext mocks base method.
+func (m *MockIRepository) RemoveWorktreeForceContext(ctx context.Context, worktreeDest string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "RemoveWorktreeForceContext", ctx, worktreeDest)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// RemoveWorktreeForceContext indicates an expected call of RemoveWorktreeForceContext.
+func (mr *MockIRepositoryMockRecorder) RemoveWorktreeForceContext(ctx, worktreeDest interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RemoveWorktreeForceContext", reflect.TypeOf((*MockIRepository)(nil).RemoveWorktreeForceContext), ctx, worktreeDest)
+}
+
+// RepairWorktrees mocks base method.
+func (m *MockIRepository) RepairWorktrees(worktreeDests ...string) error {
+	m.ctrl.T.Helper()
+	varargs := []interface{}{}
+	for _, a := range worktreeDests {
+		varargs = append(varargs, a)
+	}
+	ret := m.ctrl.Call(m, "RepairWorktrees", varargs...)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// RepairWorktrees indicates an expected call of RepairWorktrees.
+func (mr *MockIRepositoryMockRecorder) RepairWorktrees(worktreeDests ...interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RepairWorktrees", reflect.TypeOf((*MockIRepository)(nil).RepairWorktrees), worktreeDests...)
+}
+
+// RepairWorktreesContext mocks base method.
+func (m *MockIRepository) RepairWorktreesContext(ctx context.Context, worktreeDests ...string) error {
+	m.ctrl.T.Helper()
+	varargs := []interface{}{ctx}
+	for _, a := range worktreeDests {
+		varargs = append(varargs, a)
+	}
+	ret := m.ctrl.Call(m, "RepairWorktreesContext", varargs...)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// RepairWorktreesContext indicates an expected call of RepairWorktreesContext.
+func (mr *MockIRepositoryMockRecorder) RepairWorktreesContext(ctx interface{}, worktreeDests ...interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	varargs := append([]interface{}{ctx}, worktreeDests...)
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "RepairWorktreesContext", reflect.TypeOf((*MockIRepository)(nil).RepairWorktreesContext), varargs...)
+}
+
 // SetBasicAuthHeader mocks base method.
 func (m *MockIRepository) SetBasicAuthHeader(arg0 string) {
 	m.ctrl.T.Helper()
@@ -191,6 +982,18 @@ func (mr *MockIRepositoryMockRecorder) SetBasicAuthHeader(arg0 interface{}) *gom
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SetBasicAuthHeader", reflect.TypeOf((*MockIRepository)(nil).SetBasicAuthHeader), arg0)
 }
 
+// SetCredentials mocks base method.
+func (m *MockIRepository) SetCredentials(username, password string) {
+	m.ctrl.T.Helper()
+	m.ctrl.Call(m, "SetCredentials", username, password)
+}
+
+// SetCredentials indicates an expected call of SetCredentials.
+func (mr *MockIRepositoryMockRecorder) SetCredentials(username, password interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SetCredentials", reflect.TypeOf((*MockIRepository)(nil).SetCredentials), username, password)
+}
+
 // SetPrivate mocks base method.
 func (m *MockIRepository) SetPrivate(isPrivate bool) {
 	m.ctrl.T.Helper()
@@ -215,6 +1018,46 @@ func (mr *MockIRepositoryMockRecorder) SetProtol(protocol interface{}) *gomock.C
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SetProtol", reflect.TypeOf((*MockIRepository)(nil).SetProtol), protocol)
 }
 
+// SetSSHKey mocks base method.
+func (m *MockIRepository) SetSSHKey(key *git_wrapper.SSHKey) {
+	m.ctrl.T.Helper()
+	m.ctrl.Call(m, "SetSSHKey", key)
+}
+
+// SetSSHKey indicates an expected call of SetSSHKey.
+func (mr *MockIRepositoryMockRecorder) SetSSHKey(key interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "SetSSHKey", reflect.TypeOf((*MockIRepository)(nil).SetSSHKey), key)
+}
+
+// UnlockWorktree mocks base method.
+func (m *MockIRepository) UnlockWorktree(worktreeDest string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UnlockWorktree", worktreeDest)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// UnlockWorktree indicates an expected call of UnlockWorktree.
+func (mr *MockIRepositoryMockRecorder) UnlockWorktree(worktreeDest interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UnlockWorktree", reflect.TypeOf((*MockIRepository)(nil).UnlockWorktree), worktreeDest)
+}
+
+// UnlockWorktreeContext mocks base method.
+func (m *MockIRepository) UnlockWorktreeContext(ctx context.Context, worktreeDest string) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UnlockWorktreeContext", ctx, worktreeDest)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// UnlockWorktreeContext indicates an expected call of UnlockWorktreeContext.
+func (mr *MockIRepositoryMockRecorder) UnlockWorktreeContext(ctx, worktreeDest interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UnlockWorktreeContext", reflect.TypeOf((*MockIRepository)(nil).UnlockWorktreeContext), ctx, worktreeDest)
+}
+
 // UpdateRemoteOrigin mocks base method.
 func (m *MockIRepository) UpdateRemoteOrigin(remoteUrl string, logger logger.ILogger) error {
 	m.ctrl.T.Helper()
@@ -228,3 +1071,17 @@ func (mr *MockIRepositoryMockRecorder) UpdateRemoteOrigin(remoteUrl, logger inte
 	mr.mock.ctrl.T.Helper()
 	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdateRemoteOrigin", reflect.TypeOf((*MockIRepository)(nil).UpdateRemoteOrigin), remoteUrl, logger)
 }
+
+// UpdateRemoteOriginContext mocks base method.
+func (m *MockIRepository) UpdateRemoteOriginContext(ctx context.Context, remoteUrl string, logger logger.ILogger) error {
+	m.ctrl.T.Helper()
+	ret := m.ctrl.Call(m, "UpdateRemoteOriginContext", ctx, remoteUrl, logger)
+	ret0, _ := ret[0].(error)
+	return ret0
+}
+
+// UpdateRemoteOriginContext indicates an expected call of UpdateRemoteOriginContext.
+func (mr *MockIRepositoryMockRecorder) UpdateRemoteOriginContext(ctx, remoteUrl, logger interface{}) *gomock.Call {
+	mr.mock.ctrl.T.Helper()
+	return mr.mock.ctrl.RecordCallWithMethodType(mr.mock, "UpdateRemoteOriginContext", reflect.TypeOf((*MockIRepository)(nil).UpdateRemoteOriginContext), ctx, remoteUrl, logger)
+}
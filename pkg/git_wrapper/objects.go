@@ -0,0 +1,108 @@
+package git_wrapper
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// ObjectCount is the parsed output of `git count-objects -vH`. Sizes are in KB.
+type ObjectCount struct {
+	Count         uint64
+	Size          uint64
+	InPack        uint64
+	Packs         uint64
+	SizePack      uint64
+	PrunePackable uint64
+	Garbage       uint64
+	SizeGarbage   uint64
+}
+
+// TotalSize is the footprint of the object store in KB.
+func (o ObjectCount) TotalSize() uint64 {
+	return o.Size + o.SizePack + o.SizeGarbage
+}
+
+func (r *Repository) CountObjects() (*ObjectCount, error) {
+	return r.CountObjectsContext(context.Background())
+}
+
+func (r *Repository) CountObjectsContext(ctx context.Context) (*ObjectCount, error) {
+	commandBuilder := commandBuilderFunc()
+	commandBuilder.SetDir(r.Dest)
+	commandBuilder.AddCommand("count-objects")
+	commandBuilder.AddArg("-vH")
+	output, err := commandBuilder.ExecContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return ParseCountObjects(output)
+}
+
+func ParseCountObjects(output string) (*ObjectCount, error) {
+	count := &ObjectCount{}
+	fields := map[string]*uint64{
+		"count":          &count.Count,
+		"size":           &count.Size,
+		"in-pack":        &count.InPack,
+		"packs":          &count.Packs,
+		"size-pack":      &count.SizePack,
+		"prune-packable": &count.PrunePackable,
+		"garbage":        &count.Garbage,
+		"size-garbage":   &count.SizeGarbage,
+	}
+	for _, line := range strings.Split(output, "\n") {
+		key, value, found := strings.Cut(line, ":")
+		if !found {
+			continue
+		}
+		field, ok := fields[strings.TrimSpace(key)]
+		if !ok {
+			continue
+		}
+		value = strings.TrimSpace(value)
+		var err error
+		if strings.HasPrefix(strings.TrimSpace(key), "size") {
+			*field, err = parseHumanSize(value)
+		} else {
+			*field, err = strconv.ParseUint(value, 10, 64)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("parse count-objects %s: %w", key, err)
+		}
+	}
+	return count, nil
+}
+
+var humanSizeUnits = map[string]float64{
+	"bytes": 1.0 / 1024,
+	"byte":  1.0 / 1024,
+	"KiB":   1,
+	"MiB":   1024,
+	"GiB":   1024 * 1024,
+	"TiB":   1024 * 1024 * 1024,
+}
+
+// parseHumanSize converts git's human readable sizes ("12.50 MiB") to KB,
+// rounding up. A bare number is taken as KB, which is what git prints without -H.
+func parseHumanSize(value string) (uint64, error) {
+	number, unit, _ := strings.Cut(value, " ")
+	n, err := strconv.ParseFloat(number, 64)
+	if err != nil {
+		return 0, err
+	}
+	multiplier := 1.0
+	if unit != "" {
+		var ok bool
+		multiplier, ok = humanSizeUnits[unit]
+		if !ok {
+			return 0, fmt.Errorf("unknown size unit %q", unit)
+		}
+	}
+	kb := n * multiplier
+	if kb != float64(uint64(kb)) {
+		return uint64(kb) + 1, nil
+	}
+	return uint64(kb), nil
+}
@@ -0,0 +1,12 @@
+//go:build windows
+
+package git_wrapper
+
+import (
+	"os/exec"
+	"time"
+)
+
+func setProcessGroup(cmd *exec.Cmd) {
+	cmd.WaitDelay = 5 * time.Second
+}
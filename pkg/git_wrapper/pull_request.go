@@ -0,0 +1,112 @@
+package git_wrapper
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+)
+
+type Provider string
+
+const (
+	ProviderGitHub         Provider = "github"
+	ProviderGitLab         Provider = "gitlab"
+	ProviderBitbucket      Provider = "bitbucket"
+	ProviderBitbucketCloud Provider = "bitbucket-cloud"
+	ProviderAzureDevOps    Provider = "azure-devops"
+)
+
+// ErrUnsupportedProvider is returned for providers whose pull requests can't
+// be fetched as refs, like Bitbucket Cloud.
+var ErrUnsupportedProvider = errors.New("git: pull request refs not supported by provider")
+
+// pullRequestRefLayouts are the refs each provider publishes for a pull or
+// merge request: the head of the source branch and the result of merging it
+// into the target. ProviderBitbucket is Bitbucket Server (Data Center),
+// Bitbucket Cloud publishes none and Azure DevOps only publishes the merge.
+var pullRequestRefLayouts = map[Provider]struct {
+	head  string
+	merge string
+}{
+	ProviderGitHub:      {head: "refs/pull/%d/head", merge: "refs/pull/%d/merge"},
+	ProviderGitLab:      {head: "refs/merge-requests/%d/head", merge: "refs/merge-requests/%d/merge"},
+	ProviderBitbucket:   {head: "refs/pull-requests/%d/from", merge: "refs/pull-requests/%d/merge"},
+	ProviderAzureDevOps: {merge: "refs/pull/%d/merge"},
+}
+
+// ProviderFromURL guesses the provider of a hosted repository url. Self hosted
+// instances on custom domains are not recognised.
+func ProviderFromURL(repositoryUrl string) (Provider, error) {
+	host := repositoryUrl
+	if parsed, err := url.Parse(repositoryUrl); err == nil && parsed.Host != "" {
+		host = parsed.Hostname()
+	} else if _, rest, found := strings.Cut(repositoryUrl, "@"); found {
+		// scp-like ssh syntax, git@github.com:owner/repo.git
+		host, _, _ = strings.Cut(rest, ":")
+	} else {
+		host, _, _ = strings.Cut(repositoryUrl, "/")
+	}
+	host = strings.ToLower(host)
+	switch {
+	case host == "github.com" || strings.HasSuffix(host, ".github.com"):
+		return ProviderGitHub, nil
+	case host == "gitlab.com" || strings.HasPrefix(host, "gitlab."):
+		return ProviderGitLab, nil
+	case host == "bitbucket.org":
+		return ProviderBitbucketCloud, nil
+	case strings.HasPrefix(host, "bitbucket."):
+		return ProviderBitbucket, nil
+	case host == "dev.azure.com" || strings.HasSuffix(host, ".visualstudio.com") || host == "ssh.dev.azure.com":
+		return ProviderAzureDevOps, nil
+	}
+	return "", fmt.Errorf("unknown git provider for %q", repositoryUrl)
+}
+
+type PullRequestRefs struct {
+	// Head and Merge are local refs, empty when the provider doesn't publish
+	// them or, for Merge, when the request can't be merged.
+	Head  string
+	Merge string
+}
+
+func (r *Repository) FetchPullRequest(provider Provider, number int) (*PullRequestRefs, error) {
+	return r.FetchPullRequestContext(context.Background(), provider, number)
+}
+
+// FetchPullRequestContext fetches the head and merge refs of pull request
+// number from origin into refs/remotes/origin/pull/<number>/.
+func (r *Repository) FetchPullRequestContext(ctx context.Context, provider Provider, number int) (*PullRequestRefs, error) {
+	if provider == ProviderBitbucketCloud {
+		return nil, fmt.Errorf("%w %q, fetch the source branch instead", ErrUnsupportedProvider, provider)
+	}
+	layout, found := pullRequestRefLayouts[provider]
+	if !found {
+		return nil, fmt.Errorf("unknown git provider %q", provider)
+	}
+	if number <= 0 {
+		return nil, fmt.Errorf("invalid pull request number %d", number)
+	}
+	refs := &PullRequestRefs{}
+	if layout.head != "" {
+		head := fmt.Sprintf("refs/remotes/origin/pull/%d/head", number)
+		refSpec := fmt.Sprintf("+"+layout.head+":%s", number, head)
+		if err := r.FetchWithOptionsContext(ctx, &FetchOptions{RefSpecs: []string{refSpec}}); err != nil {
+			return nil, err
+		}
+		refs.Head = head
+	}
+	merge := fmt.Sprintf("refs/remotes/origin/pull/%d/merge", number)
+	refSpec := fmt.Sprintf("+"+layout.merge+":%s", number, merge)
+	err := r.FetchWithOptionsContext(ctx, &FetchOptions{RefSpecs: []string{refSpec}})
+	switch {
+	case err == nil:
+		refs.Merge = merge
+	case IsErrorKind(err, ErrorKindBadRef) && refs.Head != "":
+		// no merge ref for requests with conflicts or already closed
+	default:
+		return nil, err
+	}
+	return refs, nil
+}
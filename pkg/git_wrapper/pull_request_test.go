@@ -0,0 +1,118 @@
+package git_wrapper
+
+import (
+	"errors"
+	mock_git_wrapper "operarius/mock/pkg/git_wrapper"
+
+	"github.com/golang/mock/gomock"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+var _ = Describe("Pull request unit test", func() {
+	var mockCtrl *gomock.Controller
+	var mockCommandBuilder *mock_git_wrapper.MockICommandBuilder
+	old := commandBuilderFunc
+	BeforeEach(func() {
+		mockCtrl = gomock.NewController(GinkgoT())
+		mockCommandBuilder = mock_git_wrapper.NewMockICommandBuilder(mockCtrl)
+		commandBuilderFunc = func() ICommandBuilder {
+			return mockCommandBuilder
+		}
+	})
+	AfterEach(func() {
+		defer func() { commandBuilderFunc = old }()
+	})
+
+	DescribeTable("ProviderFromURL(repositoryUrl string) (Provider, error)",
+		func(repositoryUrl string, expected Provider) {
+			provider, err := ProviderFromURL(repositoryUrl)
+			Expect(err).Should(BeNil())
+			Expect(provider).Should(Equal(expected))
+		},
+		Entry("github https", "https://github.com/guardrailsio/core-api.git", ProviderGitHub),
+		Entry("github scp", "git@github.com:guardrailsio/core-api.git", ProviderGitHub),
+		Entry("github without scheme", "github.com/guardrailsio/core-api.git", ProviderGitHub),
+		Entry("gitlab self hosted", "ssh://git@gitlab.example.com:2222/group/repo.git", ProviderGitLab),
+		Entry("bitbucket cloud", "https://bitbucket.org/team/repo.git", ProviderBitbucketCloud),
+		Entry("bitbucket server", "ssh://git@bitbucket.example.com:7999/project/repo.git", ProviderBitbucket),
+		Entry("azure devops", "https://org@dev.azure.com/org/project/_git/repo", ProviderAzureDevOps),
+		Entry("azure devops legacy", "https://org.visualstudio.com/project/_git/repo", ProviderAzureDevOps),
+	)
+
+	It("Should reject unknown providers", func() {
+		_, err := ProviderFromURL("https://git.example.com/repo.git")
+		Expect(err).ShouldNot(BeNil())
+	})
+
+	Context("FetchPullRequest(provider Provider, number int) (*PullRequestRefs, error)", func() {
+		expectFetch := func(refSpec string, err error) *gomock.Call {
+			mockCommandBuilder.EXPECT().AddCommand("fetch")
+			mockCommandBuilder.EXPECT().AddArgs([]string{"origin", refSpec})
+			return mockCommandBuilder.EXPECT().ExecContext(gomock.Any()).Return("", err)
+		}
+
+		It("Should fetch the head and merge refs of a GitHub pull request", func() {
+			repository := NewRepository("", "/tmp/scan")
+			mockCommandBuilder.EXPECT().SetDir("/tmp/scan").AnyTimes()
+			gomock.InOrder(
+				expectFetch("+refs/pull/42/head:refs/remotes/origin/pull/42/head", nil),
+				expectFetch("+refs/pull/42/merge:refs/remotes/origin/pull/42/merge", nil),
+			)
+			refs, err := repository.FetchPullRequest(ProviderGitHub, 42)
+			Expect(err).Should(BeNil())
+			Expect(refs).Should(Equal(&PullRequestRefs{
+				Head:  "refs/remotes/origin/pull/42/head",
+				Merge: "refs/remotes/origin/pull/42/merge",
+			}))
+		})
+
+		It("Should tolerate a missing merge ref of a GitLab merge request", func() {
+			repository := NewRepository("", "/tmp/scan")
+			mockCommandBuilder.EXPECT().SetDir("/tmp/scan").AnyTimes()
+			missing := newGitError("git", []string{"fetch"}, errors.New("exit status 128"), "fatal: couldn't find remote ref refs/merge-requests/7/merge")
+			gomock.InOrder(
+				expectFetch("+refs/merge-requests/7/head:refs/remotes/origin/pull/7/head", nil),
+				expectFetch("+refs/merge-requests/7/merge:refs/remotes/origin/pull/7/merge", missing),
+			)
+			refs, err := repository.FetchPullRequest(ProviderGitLab, 7)
+			Expect(err).Should(BeNil())
+			Expect(refs).Should(Equal(&PullRequestRefs{Head: "refs/remotes/origin/pull/7/head"}))
+		})
+
+		It("Should use the Bitbucket layout", func() {
+			repository := NewRepository("", "/tmp/scan")
+			mockCommandBuilder.EXPECT().SetDir("/tmp/scan").AnyTimes()
+			gomock.InOrder(
+				expectFetch("+refs/pull-requests/3/from:refs/remotes/origin/pull/3/head", nil),
+				expectFetch("+refs/pull-requests/3/merge:refs/remotes/origin/pull/3/merge", nil),
+			)
+			_, err := repository.FetchPullRequest(ProviderBitbucket, 3)
+			Expect(err).Should(BeNil())
+		})
+
+		It("Should refuse Bitbucket Cloud, which publishes no pull request refs", func() {
+			repository := NewRepository("", "/tmp/scan")
+			_, err := repository.FetchPullRequest(ProviderBitbucketCloud, 3)
+			Expect(errors.Is(err, ErrUnsupportedProvider)).Should(BeTrue())
+		})
+
+		It("Should only fetch the merge ref of an Azure DevOps pull request", func() {
+			repository := NewRepository("", "/tmp/scan")
+			mockCommandBuilder.EXPECT().SetDir("/tmp/scan").AnyTimes()
+			expectFetch("+refs/pull/9/merge:refs/remotes/origin/pull/9/merge", nil)
+			refs, err := repository.FetchPullRequest(ProviderAzureDevOps, 9)
+			Expect(err).Should(BeNil())
+			Expect(refs).Should(Equal(&PullRequestRefs{Merge: "refs/remotes/origin/pull/9/merge"}))
+		})
+
+		It("Should fail when the pull request doesn't exist", func() {
+			repository := NewRepository("", "/tmp/scan")
+			mockCommandBuilder.EXPECT().SetDir("/tmp/scan").AnyTimes()
+			missing := newGitError("git", []string{"fetch"}, errors.New("exit status 128"), "fatal: couldn't find remote ref refs/pull/1/head")
+			expectFetch("+refs/pull/1/head:refs/remotes/origin/pull/1/head", missing)
+			_, err := repository.FetchPullRequest(ProviderGitHub, 1)
+			Expect(err).Should(MatchError(ErrBadRef))
+		})
+	})
+})
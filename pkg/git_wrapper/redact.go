@@ -0,0 +1,41 @@
+package git_wrapper
+
+import (
+	"regexp"
+	"sort"
+	"strings"
+)
+
+const redactedMask = "***"
+
+var (
+	authHeaderPattern  = regexp.MustCompile(`(?i)(authorization:\s*\w+\s+)\S+`)
+	urlUserinfoPattern = regexp.MustCompile(`(?i)([a-z][a-z0-9+.-]*://)[^/\s]*@`)
+)
+
+// redact masks the given secrets, the credentials of urls and the value of
+// authorization headers in s. Longer secrets are masked first so that one
+// containing another isn't left partially visible.
+func redact(s string, secrets []string) string {
+	if len(secrets) > 0 {
+		sorted := append([]string{}, secrets...)
+		sort.Slice(sorted, func(i, j int) bool { return len(sorted[i]) > len(sorted[j]) })
+		for _, secret := range sorted {
+			s = strings.ReplaceAll(s, secret, redactedMask)
+		}
+	}
+	s = authHeaderPattern.ReplaceAllString(s, "${1}"+redactedMask)
+	return urlUserinfoPattern.ReplaceAllString(s, "${1}"+redactedMask+"@")
+}
+
+func redactArgs(args []string, secrets []string) []string {
+	redacted := make([]string, 0, len(args))
+	for _, arg := range args {
+		redacted = append(redacted, redact(arg, secrets))
+	}
+	return redacted
+}
+
+func redactCommand(baseCommand string, args []string) string {
+	return strings.Join(append([]string{baseCommand}, redactArgs(args, nil)...), " ")
+}
@@ -1,10 +1,14 @@
 package git_wrapper
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"log"
 	"os"
 	"strings"
+	"sync"
+	"time"
 
 	"github.com/samber/lo"
 
@@ -17,6 +21,20 @@ type Repository struct {
 	Branch          Branch     `json:"branch"`
 	BasicAuthHeader string     `json:"basic_auth_header"`
 	Worktrees       []Worktree `json:"worktrees"`
+	// Head is the commit checked out, empty in a repository without commits.
+	Head string `json:"head,omitempty"`
+	// DefaultBranch is the branch origin/HEAD points to, empty when unknown.
+	DefaultBranch string `json:"default_branch,omitempty"`
+	// Protocol is ssh or https, guessed from Url when empty.
+	Protocol string `json:"protocol,omitempty"`
+	Private  bool   `json:"private,omitempty"`
+	// Credentials and SSHKey are kept in memory only, never in the cache
+	// metadata.
+	Credentials *Credentials `json:"-"`
+	SSHKey      *SSHKey      `json:"-"`
+
+	objectsMu sync.Mutex
+	objects   *objectReader
 }
 
 // SetBasicAuthHeader implements IRepository
@@ -24,42 +42,182 @@ func (r *Repository) SetBasicAuthHeader(basicAuthHeader string) {
 	r.BasicAuthHeader = basicAuthHeader
 }
 
+// SetCredentials implements IRepository
+func (r *Repository) SetCredentials(username string, password string) {
+	r.Credentials = &Credentials{Username: username, Password: password}
+}
+
+// SetSSHKey implements IRepository
+func (r *Repository) SetSSHKey(key *SSHKey) {
+	r.SSHKey = key
+}
+
 // GetDestination implements IRepository
 func (r *Repository) GetDestination() string {
 	return r.Dest
 }
 
+// GetWorktrees implements IRepository
+func (r *Repository) GetWorktrees() []Worktree {
+	return r.Worktrees
+}
+
 // Load implements IRepository
-func (*Repository) Load(url string, dest string) *Repository {
-	panic("unimplemented")
+func (r *Repository) Load(url string, dest string) (*Repository, error) {
+	return r.LoadContext(context.Background(), url, dest)
+}
+
+// LoadContext reads the state of the checkout at dest: the origin url, the
+// current branch, the HEAD commit, the default branch and the worktrees. url
+// is only used when the repository has no origin. Credentials embedded in the
+// origin url are moved to the repository like UpdateRemoteOriginContext does.
+func (r *Repository) LoadContext(ctx context.Context, url string, dest string) (*Repository, error) {
+	r.Dest = dest
+	worktrees, err := listWorktreeFunc(ctx, dest)
+	if err != nil {
+		return nil, err
+	}
+	r.Worktrees = worktrees
+	origin, err := r.queryOptional(ctx, "config", "--get", "remote.origin.url")
+	if err != nil {
+		return nil, err
+	}
+	r.Url = url
+	if origin != "" {
+		var credentials *Credentials
+		r.Url, credentials = splitURLCredentials(origin)
+		if credentials != nil {
+			r.Credentials = credentials
+		}
+	}
+	branch, err := r.queryOptional(ctx, "symbolic-ref", "--quiet", "--short", "HEAD")
+	if err != nil {
+		return nil, err
+	}
+	r.Branch = NewBranch(branch)
+	r.Head, err = r.queryOptional(ctx, "rev-parse", "--quiet", "--verify", "HEAD^{commit}")
+	if err != nil {
+		return nil, err
+	}
+	defaultBranch, err := r.queryOptional(ctx, "symbolic-ref", "--quiet", "--short", "refs/remotes/origin/HEAD")
+	if err != nil {
+		return nil, err
+	}
+	r.DefaultBranch = strings.TrimPrefix(defaultBranch, "origin/")
+	return r, nil
 }
 
-// SetPrivate implements IRepository
-func (*Repository) SetPrivate(isPrivate bool) {
-	panic("unimplemented")
+// queryOptional runs a git query exiting with status 1 when what it looks for
+// doesn't exist, like a detached HEAD or a missing config key, and returns an
+// empty output in that case.
+func (r *Repository) queryOptional(ctx context.Context, command string, args ...string) (string, error) {
+	commandBuilder := commandBuilderFunc()
+	commandBuilder.SetDir(r.Dest)
+	commandBuilder.AddCommand(command)
+	commandBuilder.AddArgs(args)
+	output, err := commandBuilder.ExecContext(ctx)
+	var gitErr *GitError
+	if errors.As(err, &gitErr) && gitErr.ExitCode == 1 {
+		return "", nil
+	}
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(output), nil
 }
 
-// SetProtol implements IRepository
-func (*Repository) SetProtol(protocol string) {
-	panic("unimplemented")
+// SetPrivate implements IRepository. Commands talking to the remote of a
+// private repository fail early when no credentials or key are set.
+func (r *Repository) SetPrivate(isPrivate bool) {
+	r.Private = isPrivate
+}
+
+// SetProtol implements IRepository. The protocol selects the SSHKey or the
+// https credentials to authenticate with.
+func (r *Repository) SetProtol(protocol string) {
+	r.Protocol = strings.ToLower(protocol)
+}
+
+func (r *Repository) protocol() string {
+	if r.Protocol != "" {
+		return r.Protocol
+	}
+	return protocolFromURL(r.Url)
 }
 
 type IRepository interface {
-	Load(url string, dest string) *Repository
+	Load(url string, dest string) (*Repository, error)
+	LoadContext(ctx context.Context, url string, dest string) (*Repository, error)
 	SetPrivate(isPrivate bool)
 	SetProtol(protocol string)
+	SetSSHKey(key *SSHKey)
 	Branches() ([]string, error)
+	BranchesContext(ctx context.Context) ([]string, error)
 	CheckoutBranch(branch string) (*Branch, error)
+	CheckoutBranchContext(ctx context.Context, branch string) (*Branch, error)
 	CheckoutCommit(commit string) (*Commit, error)
+	CheckoutCommitContext(ctx context.Context, commit string) (*Commit, error)
 	AddWorktree(path string, commitSHA string) (*Worktree, error)
+	AddWorktreeContext(ctx context.Context, path string, commitSHA string) (*Worktree, error)
+	AddWorktreeWithOptions(path string, commitSHA string, option *WorktreeOptions) (*Worktree, error)
+	AddWorktreeWithOptionsContext(ctx context.Context, path string, commitSHA string, option *WorktreeOptions) (*Worktree, error)
 	UpdateRemoteOrigin(remoteUrl string, logger logger.ILogger) error
+	UpdateRemoteOriginContext(ctx context.Context, remoteUrl string, logger logger.ILogger) error
 	FlushWorktree() error
+	FlushWorktreeContext(ctx context.Context) error
 	Fetch() error
+	FetchContext(ctx context.Context) error
+	FetchWithOptions(option *FetchOptions) error
+	FetchWithOptionsContext(ctx context.Context, option *FetchOptions) error
+	FetchCommit(commitSHA string) error
+	FetchCommitContext(ctx context.Context, commitSHA string) error
+	FetchPullRequest(provider Provider, number int) (*PullRequestRefs, error)
+	FetchPullRequestContext(ctx context.Context, provider Provider, number int) (*PullRequestRefs, error)
+	IsShallow() (bool, error)
+	IsShallowContext(ctx context.Context) (bool, error)
+	HasCommit(commitSHA string) (bool, error)
+	HasCommitContext(ctx context.Context, commitSHA string) (bool, error)
+	DeepenUntilCommit(commitSHA string, step int) error
+	DeepenUntilCommitContext(ctx context.Context, commitSHA string, step int) error
 	Pull() error
+	PullContext(ctx context.Context) error
 	GetDestination() string
+	GetWorktrees() []Worktree
+	CountObjects() (*ObjectCount, error)
+	CountObjectsContext(ctx context.Context) (*ObjectCount, error)
 	RemoveRepository() error
+	RemoveRepositoryContext(ctx context.Context) error
+	RemoveWorktree(worktreeDest string) error
+	RemoveWorktreeContext(ctx context.Context, worktreeDest string) error
+	RemoveWorktreeForce(worktreeDest string) error
+	RemoveWorktreeForceContext(ctx context.Context, worktreeDest string) error
+	LockWorktree(worktreeDest string, reason string) error
+	LockWorktreeContext(ctx context.Context, worktreeDest string, reason string) error
+	UnlockWorktree(worktreeDest string) error
+	UnlockWorktreeContext(ctx context.Context, worktreeDest string) error
+	MoveWorktree(worktreeDest string, newPath string) error
+	MoveWorktreeContext(ctx context.Context, worktreeDest string, newPath string) error
+	RepairWorktrees(worktreeDests ...string) error
+	RepairWorktreesContext(ctx context.Context, worktreeDests ...string) error
+	PruneWorktrees(expire time.Duration) error
+	PruneWorktreesContext(ctx context.Context, expire time.Duration) error
 	SetBasicAuthHeader(string)
+	SetCredentials(username string, password string)
 	GetDiffContentBetweenCommits(commit, target string) (string, error)
+	GetDiffContentBetweenCommitsContext(ctx context.Context, commit, target string) (string, error)
+	GetDiffBetweenCommits(commit, target string) ([]FileDiff, error)
+	GetDiffBetweenCommitsContext(ctx context.Context, commit, target string) ([]FileDiff, error)
+	Log(option *LogOptions) ([]CommitInfo, error)
+	LogContext(ctx context.Context, option *LogOptions) ([]CommitInfo, error)
+	ReadBlob(rev string, path string) ([]byte, error)
+	ReadBlobContext(ctx context.Context, rev string, path string) ([]byte, error)
+	PathExists(rev string, path string) (bool, error)
+	PathExistsContext(ctx context.Context, rev string, path string) (bool, error)
+	ListTree(rev string, paths ...string) ([]TreeEntry, error)
+	ListTreeContext(ctx context.Context, rev string, paths ...string) ([]TreeEntry, error)
+	ForEachBlob(option *BlobIteratorOptions, fn func(HistoryBlob) error) error
+	ForEachBlobContext(ctx context.Context, option *BlobIteratorOptions, fn func(HistoryBlob) error) error
+	Close() error
 }
 
 func NewRepository(url string, dest string) *Repository {
@@ -70,14 +228,12 @@ func NewRepository(url string, dest string) *Repository {
 }
 
 func Load(dest string) (IRepository, error) {
-	worktrees, err := ListWorktree(dest)
-	if err != nil {
-		return nil, err
-	}
-	return &Repository{
-		Dest:      dest,
-		Worktrees: worktrees,
-	}, nil
+	return LoadContext(context.Background(), dest)
+}
+
+// LoadContext opens the existing checkout at dest, see Repository.LoadContext.
+func LoadContext(ctx context.Context, dest string) (IRepository, error) {
+	return (&Repository{}).LoadContext(ctx, "", dest)
 }
 
 func (r *Repository) SetBranch(branch Branch) {
@@ -85,10 +241,14 @@ func (r *Repository) SetBranch(branch Branch) {
 }
 
 func (r *Repository) Branches() ([]string, error) {
+	return r.BranchesContext(context.Background())
+}
+
+func (r *Repository) BranchesContext(ctx context.Context) ([]string, error) {
 	commandBuilder := commandBuilderFunc()
 	commandBuilder.SetDir(r.Dest)
 	commandBuilder.AddCommand("branch")
-	output, err := commandBuilder.Exec()
+	output, err := commandBuilder.ExecContext(ctx)
 	formatedOutput := strings.Split(output, "\n")
 	formatedOutput = lo.FilterMap(formatedOutput, func(s string, i int) (string, bool) {
 		formated := strings.Trim(strings.Trim(s, " "), "* ")
@@ -101,11 +261,15 @@ func (r *Repository) Branches() ([]string, error) {
 }
 
 func (r *Repository) CheckoutBranch(branch string) (*Branch, error) {
+	return r.CheckoutBranchContext(context.Background(), branch)
+}
+
+func (r *Repository) CheckoutBranchContext(ctx context.Context, branch string) (*Branch, error) {
 	commandBuilder := commandBuilderFunc()
 	commandBuilder.SetDir(r.Dest)
 	commandBuilder.AddCommand("checkout")
 	commandBuilder.AddArg(branch)
-	_, err := commandBuilder.Exec()
+	_, err := commandBuilder.ExecContext(ctx)
 	if err != nil {
 		return nil, err
 	}
@@ -114,11 +278,15 @@ func (r *Repository) CheckoutBranch(branch string) (*Branch, error) {
 }
 
 func (r *Repository) CheckoutCommit(commit string) (*Commit, error) {
+	return r.CheckoutCommitContext(context.Background(), commit)
+}
+
+func (r *Repository) CheckoutCommitContext(ctx context.Context, commit string) (*Commit, error) {
 	commandBuilder := commandBuilderFunc()
 	commandBuilder.SetDir(r.Dest)
 	commandBuilder.AddCommand("checkout")
 	commandBuilder.AddArg(commit)
-	_, err := commandBuilder.Exec()
+	_, err := commandBuilder.ExecContext(ctx)
 	if err != nil {
 		return nil, err
 	}
@@ -127,47 +295,104 @@ func (r *Repository) CheckoutCommit(commit string) (*Commit, error) {
 }
 
 func (r *Repository) AddWorktree(path string, commitSHA string) (*Worktree, error) {
+	return r.AddWorktreeContext(context.Background(), path, commitSHA)
+}
+
+func (r *Repository) AddWorktreeContext(ctx context.Context, path string, commitSHA string) (*Worktree, error) {
+	return r.AddWorktreeWithOptionsContext(ctx, path, commitSHA, nil)
+}
+
+func (r *Repository) AddWorktreeWithOptions(path string, commitSHA string, option *WorktreeOptions) (*Worktree, error) {
+	return r.AddWorktreeWithOptionsContext(context.Background(), path, commitSHA, option)
+}
+
+// AddWorktreeWithOptionsContext adds a worktree like AddWorktreeContext. With
+// SparsePaths only those directories are checked out, which together with a
+// partial clone (CloneOptions.FilterSpec) also limits the blobs fetched.
+func (r *Repository) AddWorktreeWithOptionsContext(ctx context.Context, path string, commitSHA string, option *WorktreeOptions) (*Worktree, error) {
 	for _, worktree := range r.Worktrees {
-		if worktree.Path == path && strings.HasPrefix(commitSHA, worktree.CommitSHA) { // worktree is short sha, but scan request is a full sha, so it's okay to check just prefix
+		if worktree.Path == path && sameCommitSHA(worktree.CommitSHA, commitSHA) {
 			return &worktree, nil
 		}
 	}
+	args := []string{"add"}
+	if option.noCheckout() {
+		args = append(args, "--no-checkout")
+	}
 	commandBuilder := commandBuilderFunc()
 	commandBuilder.SetDir(r.Dest)
 	commandBuilder.AddCommand("worktree")
-	commandBuilder.AddArgs([]string{"add", path})
+	commandBuilder.AddArgs(append(args, path))
 	if commitSHA != "" {
 		commandBuilder.AddArg(commitSHA)
 	}
-	_, err := commandBuilder.Exec()
+	_, err := commandBuilder.ExecContext(ctx)
 	if err != nil {
 		return nil, err
 	}
 	w := NewWorkTree(path, commitSHA)
+	if option != nil && len(option.SparsePaths) > 0 {
+		if err := w.SetSparseCheckoutContext(ctx, option.SparsePaths); err != nil {
+			// don't leave a worktree with an empty checkout behind
+			runWorktreeCommand(context.WithoutCancel(ctx), r.Dest, []string{"remove", "--force", "--force", path})
+			return nil, err
+		}
+	}
 	r.Worktrees = append(r.Worktrees, w)
 	return &w, nil
 }
 
+// minAbbreviatedSHA is the shortest abbreviation git prints for a commit.
+const minAbbreviatedSHA = 7
+
+// sameCommitSHA matches shas either of which may be abbreviated. Empty or
+// shorter values, like a worktree listed without HEAD, never match.
+func sameCommitSHA(a string, b string) bool {
+	if len(a) < minAbbreviatedSHA || len(b) < minAbbreviatedSHA {
+		return false
+	}
+	return strings.HasPrefix(a, b) || strings.HasPrefix(b, a)
+}
+
 func (r *Repository) Fetch() error {
+	return r.FetchContext(context.Background())
+}
+
+func (r *Repository) FetchContext(ctx context.Context) error {
 	commandBuilder := commandBuilderFunc()
-	addBasicAuthHeader(commandBuilder, r.BasicAuthHeader)
+	cleanup, err := r.addAuth(commandBuilder)
+	if err != nil {
+		return err
+	}
+	defer cleanup()
 	commandBuilder.SetDir(r.Dest)
 	commandBuilder.AddCommand("fetch")
-	_, err := commandBuilder.Exec()
+	_, err = commandBuilder.ExecContext(ctx)
 	return err
 }
 
 func (r *Repository) Pull() error {
+	return r.PullContext(context.Background())
+}
+
+func (r *Repository) PullContext(ctx context.Context) error {
 	commandBuilder := commandBuilderFunc()
-	addBasicAuthHeader(commandBuilder, r.BasicAuthHeader)
+	cleanup, err := r.addAuth(commandBuilder)
+	if err != nil {
+		return err
+	}
+	defer cleanup()
 	commandBuilder.SetDir(r.Dest)
 	commandBuilder.AddCommand("pull")
-	commandBuilder.Exec()
-	_, err := commandBuilder.Exec()
+	_, err = commandBuilder.ExecContext(ctx)
 	return err
 }
 
 func (r *Repository) FlushWorktree() error {
+	return r.FlushWorktreeContext(context.Background())
+}
+
+func (r *Repository) FlushWorktreeContext(ctx context.Context) error {
 	for _, w := range r.Worktrees {
 		if w.IsMain {
 			continue
@@ -176,7 +401,7 @@ func (r *Repository) FlushWorktree() error {
 		commandBuilder.SetDir(r.Dest)
 		commandBuilder.AddCommand("worktree")
 		commandBuilder.AddArgs([]string{"remove", w.Path})
-		_, err := commandBuilder.Exec()
+		_, err := commandBuilder.ExecContext(ctx)
 		if err != nil {
 			return err
 		}
@@ -184,58 +409,213 @@ func (r *Repository) FlushWorktree() error {
 	return nil
 }
 
+// addAuth passes the repository secrets to commands talking to the remote.
+// The returned cleanup removes the temporary files of an SSHKey.
+func (r *Repository) addAuth(cmd ICommandBuilder) (func(), error) {
+	if r.protocol() == ProtocolSSH {
+		if r.Private && r.SSHKey == nil {
+			return nil, fmt.Errorf("%w: no ssh key for private repository %s", ErrAuthFailed, r.Url)
+		}
+		return r.SSHKey.apply(cmd)
+	}
+	if r.Private && r.BasicAuthHeader == "" && (r.Credentials == nil || r.Credentials.Password == "") {
+		return nil, fmt.Errorf("%w: no credentials for private repository %s", ErrAuthFailed, r.Url)
+	}
+	addBasicAuthHeader(cmd, r.BasicAuthHeader)
+	if err := r.Credentials.apply(cmd, r.Url); err != nil {
+		return nil, err
+	}
+	return func() {}, nil
+}
+
 func addBasicAuthHeader(cmd ICommandBuilder, token string) {
 	if token != "" {
-		basicAuthHeaderArgs := []string{"-c", fmt.Sprintf("http.extraHeader=Authorization: Basic %s", token)}
-		cmd.AddBaseCommandArgs(basicAuthHeaderArgs)
+		cmd.AddSecret(token)
+		cmd.AddConfig("http.extraHeader", "Authorization: Basic "+token)
 	}
 }
 
 func (r *Repository) UpdateRemoteOrigin(remoteUrl string, logger logger.ILogger) error {
+	return r.UpdateRemoteOriginContext(context.Background(), remoteUrl, logger)
+}
+
+// UpdateRemoteOriginContext points origin to remoteUrl. Credentials embedded
+// in remoteUrl are moved to the repository instead of the git config.
+func (r *Repository) UpdateRemoteOriginContext(ctx context.Context, remoteUrl string, logger logger.ILogger) error {
+	remoteUrl, credentials := splitURLCredentials(remoteUrl)
+	if credentials != nil {
+		r.Credentials = credentials
+	}
 	commandBuilder := commandBuilderFunc()
 	commandBuilder.SetDir(r.Dest)
 	commandBuilder.SetLogger(logger)
 	commandBuilder.AddCommand("remote")
 	commandBuilder.AddArgs([]string{"set-url", "origin", remoteUrl})
-	_, err := commandBuilder.Exec()
+	_, err := commandBuilder.ExecContext(ctx)
 	return err
 }
 
 func (r *Repository) RemoveWorktree(worktreeDest string) error {
-	commandBuilder := commandBuilderFunc()
-	commandBuilder.SetDir(r.Dest)
-	commandBuilder.AddCommand("worktree")
-	commandBuilder.AddArgs([]string{"remove", worktreeDest})
-	_, err := commandBuilder.Exec()
-	return err
+	return r.RemoveWorktreeContext(context.Background(), worktreeDest)
+}
+
+func (r *Repository) RemoveWorktreeContext(ctx context.Context, worktreeDest string) error {
+	err := runWorktreeCommand(ctx, r.Dest, []string{"remove", worktreeDest})
+	if err != nil {
+		return err
+	}
+	r.forgetWorktree(worktreeDest)
+	return nil
+}
+
+// RemoveWorktreeForce removes a worktree even if it is dirty or locked.
+func (r *Repository) RemoveWorktreeForce(worktreeDest string) error {
+	return r.RemoveWorktreeForceContext(context.Background(), worktreeDest)
+}
+
+func (r *Repository) RemoveWorktreeForceContext(ctx context.Context, worktreeDest string) error {
+	err := runWorktreeCommand(ctx, r.Dest, []string{"remove", "--force", "--force", worktreeDest})
+	if err != nil {
+		return err
+	}
+	r.forgetWorktree(worktreeDest)
+	return nil
+}
+
+func (r *Repository) LockWorktree(worktreeDest string, reason string) error {
+	return r.LockWorktreeContext(context.Background(), worktreeDest, reason)
+}
+
+func (r *Repository) LockWorktreeContext(ctx context.Context, worktreeDest string, reason string) error {
+	err := runWorktreeCommand(ctx, r.Dest, lockWorktreeArgs(worktreeDest, reason))
+	if err != nil {
+		return err
+	}
+	r.updateWorktree(worktreeDest, func(w *Worktree) {
+		w.Locked = true
+		w.LockReason = reason
+	})
+	return nil
+}
+
+func (r *Repository) UnlockWorktree(worktreeDest string) error {
+	return r.UnlockWorktreeContext(context.Background(), worktreeDest)
+}
+
+func (r *Repository) UnlockWorktreeContext(ctx context.Context, worktreeDest string) error {
+	err := runWorktreeCommand(ctx, r.Dest, []string{"unlock", worktreeDest})
+	if err != nil {
+		return err
+	}
+	r.updateWorktree(worktreeDest, func(w *Worktree) {
+		w.Locked = false
+		w.LockReason = ""
+	})
+	return nil
+}
+
+func (r *Repository) MoveWorktree(worktreeDest string, newPath string) error {
+	return r.MoveWorktreeContext(context.Background(), worktreeDest, newPath)
+}
+
+func (r *Repository) MoveWorktreeContext(ctx context.Context, worktreeDest string, newPath string) error {
+	err := runWorktreeCommand(ctx, r.Dest, []string{"move", worktreeDest, newPath})
+	if err != nil {
+		return err
+	}
+	r.updateWorktree(worktreeDest, func(w *Worktree) {
+		w.Path = newPath
+	})
+	return nil
+}
+
+// RepairWorktrees repairs the administrative files of the given worktrees, or
+// of every worktree when no path is given.
+func (r *Repository) RepairWorktrees(worktreeDests ...string) error {
+	return r.RepairWorktreesContext(context.Background(), worktreeDests...)
+}
+
+func (r *Repository) RepairWorktreesContext(ctx context.Context, worktreeDests ...string) error {
+	return runWorktreeCommand(ctx, r.Dest, append([]string{"repair"}, worktreeDests...))
+}
+
+// PruneWorktrees drops administrative data of worktrees whose directory is
+// gone and that are not locked. Only entries older than expire are pruned,
+// zero prunes all of them.
+func (r *Repository) PruneWorktrees(expire time.Duration) error {
+	return r.PruneWorktreesContext(context.Background(), expire)
+}
+
+func (r *Repository) PruneWorktreesContext(ctx context.Context, expire time.Duration) error {
+	err := runWorktreeCommand(ctx, r.Dest, pruneWorktreeArgs(expire))
+	if err != nil {
+		return err
+	}
+	worktrees, err := listWorktreeFunc(ctx, r.Dest)
+	if err != nil {
+		return err
+	}
+	r.Worktrees = worktrees
+	return nil
+}
+
+func (r *Repository) updateWorktree(path string, update func(*Worktree)) {
+	for i := range r.Worktrees {
+		if r.Worktrees[i].Path == path {
+			update(&r.Worktrees[i])
+		}
+	}
+}
+
+func (r *Repository) forgetWorktree(path string) {
+	r.Worktrees = lo.Filter(r.Worktrees, func(w Worktree, _ int) bool {
+		return w.Path != path
+	})
 }
 
 func (r *Repository) RemoveRepository() error {
-	for _, w := range r.Worktrees {
+	return r.RemoveRepositoryContext(context.Background())
+}
+
+// RemoveRepositoryContext deletes the repository with its linked worktrees,
+// dirty or locked ones included since the repository owns them.
+func (r *Repository) RemoveRepositoryContext(ctx context.Context) error {
+	r.Close()
+	for _, w := range append([]Worktree{}, r.Worktrees...) {
 		if w.IsMain {
 			continue
 		}
-		err := r.RemoveWorktree(w.Path)
+		err := r.RemoveWorktreeForceContext(ctx, w.Path)
 		if err != nil {
 			log.Println("remove worktree fail", err)
-			continue
+			os.RemoveAll(w.Path)
 		}
 	}
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	if err := runWorktreeCommand(ctx, r.Dest, pruneWorktreeArgs(0)); err != nil {
+		log.Println("prune worktrees fail", err)
+	}
 	err := os.RemoveAll(r.Dest)
 	return err
 }
 
 func (r *Repository) GetDiffContentBetweenCommits(commit, target string) (string, error) {
+	return r.GetDiffContentBetweenCommitsContext(context.Background(), commit, target)
+}
+
+func (r *Repository) GetDiffContentBetweenCommitsContext(ctx context.Context, commit, target string) (string, error) {
 	commandBuilder := commandBuilderFunc()
 	commandBuilder.SetDir(r.Dest)
 	if commit == target {
 		commandBuilder.AddCommand("show")
 		commandBuilder.AddArg(commit)
-		output, err := commandBuilder.Exec()
+		output, err := commandBuilder.ExecContext(ctx)
 		return output, err
 	}
 	commandBuilder.AddCommand("diff")
 	commandBuilder.AddArg(fmt.Sprintf("%s..%s", target, commit))
-	output, err := commandBuilder.Exec()
+	output, err := commandBuilder.ExecContext(ctx)
 	return output, err
 }
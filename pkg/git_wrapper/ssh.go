@@ -0,0 +1,117 @@
+package git_wrapper
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+const (
+	ProtocolHTTPS = "https"
+	ProtocolSSH   = "ssh"
+
+	sshPassphraseEnv = "OPERARIUS_SSH_PASSPHRASE"
+	// sshAskpass answers the passphrase prompt of ssh from the environment of
+	// the git process.
+	sshAskpass = "#!/bin/sh\nprintf '%s\\n' \"$" + sshPassphraseEnv + "\"\n"
+)
+
+// SSHKey authenticates git over ssh, typically with a deploy key.
+type SSHKey struct {
+	// PrivateKey is the content of the key. PrivateKeyPath is used when empty.
+	PrivateKey     []byte
+	PrivateKeyPath string
+	Passphrase     string
+	// KnownHosts is the content of a known_hosts file the host key is checked
+	// against, the user's known_hosts being used when empty. Unknown or
+	// changed host keys are rejected unless InsecureIgnoreHostKey is set.
+	KnownHosts            []byte
+	InsecureIgnoreHostKey bool
+}
+
+// apply points GIT_SSH_COMMAND to an ssh using the key. The key, known hosts
+// and askpass program are written to a temporary directory removed by the
+// returned cleanup once the command has run.
+func (k *SSHKey) apply(commandBuilder ICommandBuilder) (func(), error) {
+	if k == nil {
+		return func() {}, nil
+	}
+	if len(k.PrivateKey) == 0 && k.PrivateKeyPath == "" {
+		return nil, fmt.Errorf("ssh key without private key")
+	}
+	dir, err := os.MkdirTemp("", "git-ssh-")
+	if err != nil {
+		return nil, err
+	}
+	cleanup := func() { os.RemoveAll(dir) }
+	keyPath := k.PrivateKeyPath
+	if len(k.PrivateKey) > 0 {
+		keyPath = filepath.Join(dir, "id")
+		key := k.PrivateKey
+		// ssh refuses keys without a final newline
+		if !bytes.HasSuffix(key, []byte("\n")) {
+			key = append(append([]byte{}, key...), '\n')
+		}
+		if err := os.WriteFile(keyPath, key, 0o600); err != nil {
+			cleanup()
+			return nil, err
+		}
+	}
+	options := []string{
+		"ssh", "-i", shellQuote(keyPath),
+		"-o", "IdentitiesOnly=yes",
+		"-o", "PasswordAuthentication=no",
+		"-o", "KbdInteractiveAuthentication=no",
+	}
+	switch {
+	case k.InsecureIgnoreHostKey:
+		options = append(options, "-o", "StrictHostKeyChecking=no", "-o", "UserKnownHostsFile=/dev/null")
+	case len(k.KnownHosts) > 0:
+		knownHostsPath := filepath.Join(dir, "known_hosts")
+		if err := os.WriteFile(knownHostsPath, k.KnownHosts, 0o600); err != nil {
+			cleanup()
+			return nil, err
+		}
+		options = append(options, "-o", "StrictHostKeyChecking=yes", "-o", "UserKnownHostsFile="+shellQuote(knownHostsPath))
+	default:
+		options = append(options, "-o", "StrictHostKeyChecking=yes")
+	}
+	if k.Passphrase != "" {
+		askpassPath := filepath.Join(dir, "askpass")
+		if err := os.WriteFile(askpassPath, []byte(sshAskpass), 0o700); err != nil {
+			cleanup()
+			return nil, err
+		}
+		commandBuilder.AddSecret(k.Passphrase)
+		commandBuilder.AddEnv(sshPassphraseEnv, k.Passphrase)
+		commandBuilder.AddEnv("SSH_ASKPASS", askpassPath)
+		commandBuilder.AddEnv("SSH_ASKPASS_REQUIRE", "force")
+	} else {
+		// never wait for input
+		options = append(options, "-o", "BatchMode=yes")
+	}
+	commandBuilder.AddEnv("GIT_SSH_COMMAND", strings.Join(options, " "))
+	return cleanup, nil
+}
+
+// protocolFromURL tells ssh urls, including the scp-like git@host:path
+// syntax, from the others.
+func protocolFromURL(rawUrl string) string {
+	scheme, _, hasScheme := strings.Cut(rawUrl, "://")
+	if !hasScheme {
+		if host, _, found := strings.Cut(rawUrl, ":"); found && !strings.Contains(host, "/") {
+			return ProtocolSSH
+		}
+		return ProtocolHTTPS
+	}
+	if scheme == "ssh" || scheme == "git+ssh" || scheme == "ssh+git" {
+		return ProtocolSSH
+	}
+	return ProtocolHTTPS
+}
+
+func shellQuote(value string) string {
+	return "'" + strings.ReplaceAll(value, "'", `'\''`) + "'"
+}
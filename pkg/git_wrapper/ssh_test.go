@@ -0,0 +1,106 @@
+package git_wrapper
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+// sshCommandEnv returns the GIT_SSH_COMMAND set on commandBuilder.
+func sshCommandEnv(commandBuilder *CommandBuilder) string {
+	for _, env := range commandBuilder.env {
+		if value, found := strings.CutPrefix(env, "GIT_SSH_COMMAND="); found {
+			return value
+		}
+	}
+	return ""
+}
+
+var _ = Describe("SSH unit test", func() {
+	DescribeTable("protocolFromURL(rawUrl string) string",
+		func(rawUrl string, protocol string) {
+			Expect(protocolFromURL(rawUrl)).To(Equal(protocol))
+		},
+		Entry("scp-like syntax", "git@github.com:guardrailsio/core-api.git", ProtocolSSH),
+		Entry("ssh scheme", "ssh://git@github.com/guardrailsio/core-api.git", ProtocolSSH),
+		Entry("https scheme", "https://github.com/guardrailsio/core-api.git", ProtocolHTTPS),
+		Entry("no scheme", "github.com/guardrailsio/core-api.git", ProtocolHTTPS),
+	)
+
+	Context("apply(commandBuilder ICommandBuilder) (func(), error)", func() {
+		It("Should check host keys against the given known hosts", func() {
+			commandBuilder := &CommandBuilder{baseCommand: "sh"}
+			cleanup, err := (&SSHKey{
+				PrivateKey: []byte("key\n"),
+				KnownHosts: []byte("github.com ssh-ed25519 AAAAC3NzaC1lZDI1NTE5AAAAIOMqqnkVzrm0SdG6UOoqKLsabgH5C9okWi0dh2l9GKJl\n"),
+			}).apply(commandBuilder)
+			Expect(err).To(BeNil())
+			commandBuilder.AddCommand("-c")
+			// git runs the command through the shell, ssh -G prints the
+			// configuration it would connect with
+			commandBuilder.AddArgs([]string{`eval "$GIT_SSH_COMMAND -G github.com"`})
+			output, err := commandBuilder.ExecContext(context.Background())
+			Expect(err).To(BeNil())
+			Expect(output).To(ContainSubstring("stricthostkeychecking true\n"))
+			Expect(output).To(ContainSubstring("batchmode yes\n"))
+			Expect(output).To(MatchRegexp(`(?m)^userknownhostsfile (\S+/known_hosts)$`))
+			Expect(output).To(MatchRegexp(`(?m)^identityfile (\S+/id)$`))
+			cleanup()
+		})
+
+		It("Should remove its files on cleanup", func() {
+			commandBuilder := &CommandBuilder{baseCommand: "git"}
+			cleanup, err := (&SSHKey{PrivateKey: []byte("key"), KnownHosts: []byte("hosts")}).apply(commandBuilder)
+			Expect(err).To(BeNil())
+			keyPath := strings.Trim(strings.Fields(sshCommandEnv(commandBuilder))[2], "'")
+			Expect(os.ReadFile(keyPath)).To(Equal([]byte("key\n")))
+			Expect(os.ReadFile(filepath.Join(filepath.Dir(keyPath), "known_hosts"))).To(Equal([]byte("hosts")))
+			cleanup()
+			Expect(filepath.Dir(keyPath)).NotTo(BeADirectory())
+		})
+
+		It("Should answer the passphrase prompt of an encrypted key", func() {
+			keyPath := filepath.Join(GinkgoT().TempDir(), "deploy key")
+			commandBuilder := &CommandBuilder{baseCommand: "ssh-keygen"}
+			commandBuilder.AddCommand("-q")
+			commandBuilder.AddArgs([]string{"-t", "ed25519", "-N", "p@ss 'w'", "-f", keyPath})
+			_, err := commandBuilder.ExecContext(context.Background())
+			Expect(err).To(BeNil())
+
+			commandBuilder = &CommandBuilder{baseCommand: "ssh-keygen"}
+			cleanup, err := (&SSHKey{PrivateKeyPath: keyPath, Passphrase: "p@ss 'w'"}).apply(commandBuilder)
+			Expect(err).To(BeNil())
+			defer cleanup()
+			Expect(sshCommandEnv(commandBuilder)).NotTo(ContainSubstring("BatchMode"))
+			commandBuilder.AddCommand("-y")
+			commandBuilder.AddArgs([]string{"-f", keyPath})
+			output, err := commandBuilder.ExecContext(context.Background())
+			Expect(err).To(BeNil())
+			Expect(output).To(HavePrefix("ssh-ed25519 "))
+		})
+
+		It("Should require a private key", func() {
+			_, err := (&SSHKey{Passphrase: "x"}).apply(NewCommandBuilder())
+			Expect(err).NotTo(BeNil())
+		})
+	})
+
+	Context("addAuth(cmd ICommandBuilder) (func(), error)", func() {
+		It("Should fail early for private repositories without credentials", func() {
+			repository := NewRepository("git@github.com:guardrailsio/core-api.git", "/tmp/scan")
+			repository.SetPrivate(true)
+			_, err := repository.addAuth(NewCommandBuilder())
+			Expect(errors.Is(err, ErrAuthFailed)).To(BeTrue())
+			repository.SetProtol("HTTPS")
+			repository.SetCredentials("guardrails", "token")
+			cleanup, err := repository.addAuth(NewCommandBuilder())
+			Expect(err).To(BeNil())
+			cleanup()
+		})
+	})
+})
@@ -0,0 +1,122 @@
+package git_wrapper
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+type TreeEntry struct {
+	Mode string
+	Type string
+	ID   string
+	// Size is -1 for trees and submodules.
+	Size int64
+	Path string
+}
+
+// objectReader returns the cat-file reader of the repository, starting it on
+// first use. It is stopped by Close.
+func (r *Repository) objectReader() *objectReader {
+	r.objectsMu.Lock()
+	defer r.objectsMu.Unlock()
+	if r.objects == nil {
+		r.objects = newObjectReader(r.Dest)
+	}
+	return r.objects
+}
+
+// Close stops the background cat-file processes used to read objects. The
+// repository can still be used afterwards, they are restarted on demand.
+func (r *Repository) Close() error {
+	r.objectsMu.Lock()
+	objects := r.objects
+	r.objects = nil
+	r.objectsMu.Unlock()
+	if objects == nil {
+		return nil
+	}
+	return objects.Close()
+}
+
+func (r *Repository) ReadBlob(rev string, path string) ([]byte, error) {
+	return r.ReadBlobContext(context.Background(), rev, path)
+}
+
+// ReadBlobContext returns the content of path at rev without a worktree.
+func (r *Repository) ReadBlobContext(ctx context.Context, rev string, path string) ([]byte, error) {
+	name := rev + ":" + path
+	info, content, err := r.objectReader().Read(ctx, name)
+	if err != nil {
+		return nil, err
+	}
+	if info.Type != "blob" {
+		return nil, fmt.Errorf("%s is a %s, not a blob", name, info.Type)
+	}
+	return content, nil
+}
+
+func (r *Repository) PathExists(rev string, path string) (bool, error) {
+	return r.PathExistsContext(context.Background(), rev, path)
+}
+
+// PathExistsContext reports whether path, a file or a directory, exists at rev.
+func (r *Repository) PathExistsContext(ctx context.Context, rev string, path string) (bool, error) {
+	_, err := r.objectReader().Info(ctx, rev+":"+path)
+	if errors.Is(err, ErrObjectNotFound) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+func (r *Repository) ListTree(rev string, paths ...string) ([]TreeEntry, error) {
+	return r.ListTreeContext(context.Background(), rev, paths...)
+}
+
+// ListTreeContext lists every file below the root of rev, recursively, limited
+// to paths when given.
+func (r *Repository) ListTreeContext(ctx context.Context, rev string, paths ...string) ([]TreeEntry, error) {
+	if strings.HasPrefix(rev, "-") {
+		return nil, fmt.Errorf("invalid revision %q", rev)
+	}
+	commandBuilder := commandBuilderFunc()
+	commandBuilder.SetDir(r.Dest)
+	commandBuilder.AddCommand("ls-tree")
+	commandBuilder.AddArgs(append([]string{"-r", "-l", "-z", "--full-tree", rev, "--"}, paths...))
+	output, err := commandBuilder.ExecContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return ParseLsTree(output)
+}
+
+// ParseLsTree parses `git ls-tree -l -z` output, one
+// "<mode> <type> <object> <size>\t<path>" entry per NUL terminated record.
+func ParseLsTree(output string) ([]TreeEntry, error) {
+	entries := []TreeEntry{}
+	for _, record := range strings.Split(output, "\x00") {
+		if record == "" {
+			continue
+		}
+		meta, path, found := strings.Cut(record, "\t")
+		fields := strings.Fields(meta)
+		if !found || len(fields) != 4 {
+			return nil, fmt.Errorf("malformed ls-tree entry %q", record)
+		}
+		entry := TreeEntry{Mode: fields[0], Type: fields[1], ID: fields[2], Size: -1, Path: path}
+		if fields[3] != "-" {
+			size, err := strconv.ParseInt(fields[3], 10, 64)
+			if err != nil {
+				return nil, fmt.Errorf("malformed ls-tree entry %q: %w", record, err)
+			}
+			entry.Size = size
+		}
+		entries = append(entries, entry)
+	}
+	return entries, nil
+}
@@ -0,0 +1,145 @@
+package git_wrapper
+
+import (
+	"context"
+	mock_git_wrapper "operarius/mock/pkg/git_wrapper"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"github.com/golang/mock/gomock"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+func runTestGit(dest string, args ...string) string {
+	cmd := exec.Command("git", append([]string{"-c", "user.name=test", "-c", "user.email=test@example.com"}, args...)...)
+	cmd.Dir = dest
+	output, err := cmd.CombinedOutput()
+	Expect(err).Should(BeNil(), string(output))
+	return strings.TrimSpace(string(output))
+}
+
+// commitTestFiles writes files, removing the ones with a nil content, commits
+// them and returns the commit sha.
+func commitTestFiles(dest string, files map[string]*string) string {
+	for path, content := range files {
+		fullPath := filepath.Join(dest, path)
+		if content == nil {
+			Expect(os.Remove(fullPath)).Should(Succeed())
+			continue
+		}
+		Expect(os.MkdirAll(filepath.Dir(fullPath), 0o755)).Should(Succeed())
+		Expect(os.WriteFile(fullPath, []byte(*content), 0o644)).Should(Succeed())
+	}
+	runTestGit(dest, "add", "-A")
+	runTestGit(dest, "commit", "-q", "-m", "update")
+	return runTestGit(dest, "rev-parse", "HEAD")
+}
+
+func initTestRepository(files map[string]string) string {
+	dest := GinkgoT().TempDir()
+	runTestGit(dest, "init", "-q")
+	contents := map[string]*string{}
+	for path, content := range files {
+		contents[path] = &content
+	}
+	commitTestFiles(dest, contents)
+	return dest
+}
+
+var _ = Describe("Tree unit test", func() {
+	Context("ListTree(rev string, paths ...string) ([]TreeEntry, error)", func() {
+		var mockCtrl *gomock.Controller
+		var mockCommandBuilder *mock_git_wrapper.MockICommandBuilder
+		old := commandBuilderFunc
+		BeforeEach(func() {
+			mockCtrl = gomock.NewController(GinkgoT())
+			mockCommandBuilder = mock_git_wrapper.NewMockICommandBuilder(mockCtrl)
+			commandBuilderFunc = func() ICommandBuilder {
+				return mockCommandBuilder
+			}
+		})
+		AfterEach(func() {
+			defer func() { commandBuilderFunc = old }()
+		})
+
+		It("Should list files with mode, type, size and object id", func() {
+			repository := NewRepository("", "/tmp/scan")
+			mockCommandBuilder.EXPECT().SetDir("/tmp/scan")
+			mockCommandBuilder.EXPECT().AddCommand("ls-tree")
+			mockCommandBuilder.EXPECT().AddArgs([]string{"-r", "-l", "-z", "--full-tree", "HEAD", "--", "sub"})
+			mockCommandBuilder.EXPECT().ExecContext(gomock.Any()).Return(
+				"100644 blob 1c5a36f5d2e2f5293d62440acef04fbbd683e447     144\tsub/c d.txt\x00"+
+					"160000 commit 9dbaf49b54e0733a4ddf5556dbf7b71be942a0fb       -\tsub/module\x00", nil)
+			entries, err := repository.ListTree("HEAD", "sub")
+			Expect(err).Should(BeNil())
+			Expect(entries).Should(Equal([]TreeEntry{
+				{Mode: "100644", Type: "blob", ID: "1c5a36f5d2e2f5293d62440acef04fbbd683e447", Size: 144, Path: "sub/c d.txt"},
+				{Mode: "160000", Type: "commit", ID: "9dbaf49b54e0733a4ddf5556dbf7b71be942a0fb", Size: -1, Path: "sub/module"},
+			}))
+		})
+	})
+
+	Context("ReadBlob(rev string, path string) ([]byte, error)", func() {
+		var repository *Repository
+		BeforeEach(func() {
+			repository = NewRepository("", initTestRepository(map[string]string{
+				"a.txt":       "hello\n",
+				"dir/b c.txt": "no newline",
+				"empty":       "",
+			}))
+			DeferCleanup(repository.Close)
+		})
+
+		It("Should read many blobs from a single process", func() {
+			for i := 0; i < 3; i++ {
+				content, err := repository.ReadBlob("HEAD", "a.txt")
+				Expect(err).Should(BeNil())
+				Expect(string(content)).Should(Equal("hello\n"))
+				content, err = repository.ReadBlob("HEAD", "dir/b c.txt")
+				Expect(err).Should(BeNil())
+				Expect(string(content)).Should(Equal("no newline"))
+				content, err = repository.ReadBlob("HEAD", "empty")
+				Expect(err).Should(BeNil())
+				Expect(content).Should(BeEmpty())
+			}
+		})
+
+		It("Should report missing files and non-blob objects", func() {
+			_, err := repository.ReadBlob("HEAD", "missing.txt")
+			Expect(err).Should(MatchError(ErrObjectNotFound))
+			_, err = repository.ReadBlob("HEAD", "dir")
+			Expect(err).ShouldNot(BeNil())
+			content, err := repository.ReadBlob("HEAD", "a.txt")
+			Expect(err).Should(BeNil())
+			Expect(string(content)).Should(Equal("hello\n"))
+		})
+
+		It("Should check existence of files and directories", func() {
+			Expect(repository.PathExists("HEAD", "dir/b c.txt")).Should(BeTrue())
+			Expect(repository.PathExists("HEAD", "dir")).Should(BeTrue())
+			Expect(repository.PathExists("HEAD", "nope")).Should(BeFalse())
+		})
+
+		It("Should stop on a cancelled context and recover afterwards", func() {
+			ctx, cancel := context.WithCancel(context.Background())
+			cancel()
+			_, err := repository.ReadBlobContext(ctx, "HEAD", "a.txt")
+			Expect(err).Should(MatchError(context.Canceled))
+			content, err := repository.ReadBlob("HEAD", "a.txt")
+			Expect(err).Should(BeNil())
+			Expect(string(content)).Should(Equal("hello\n"))
+		})
+
+		It("Should restart the processes after Close", func() {
+			_, err := repository.ReadBlob("HEAD", "a.txt")
+			Expect(err).Should(BeNil())
+			Expect(repository.Close()).Should(Succeed())
+			content, err := repository.ReadBlob("HEAD", "a.txt")
+			Expect(err).Should(BeNil())
+			Expect(string(content)).Should(Equal("hello\n"))
+		})
+	})
+})
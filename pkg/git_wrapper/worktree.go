@@ -1,15 +1,23 @@
 package git_wrapper
 
 import (
+	"context"
+	"fmt"
 	"strings"
-
-	"github.com/samber/lo"
+	"time"
 )
 
 type Worktree struct {
-	CommitSHA string
-	Path      string
-	IsMain    bool
+	CommitSHA      string
+	Path           string
+	IsMain         bool
+	Branch         string
+	Detached       bool
+	Bare           bool
+	Locked         bool
+	LockReason     string
+	Prunable       bool
+	PrunableReason string
 }
 
 func NewWorkTree(path string, commitSHA string) Worktree {
@@ -20,62 +28,195 @@ func NewWorkTree(path string, commitSHA string) Worktree {
 }
 
 func (w Worktree) Remove() error {
-	commandBuilder := commandBuilderFunc()
-	commandBuilder.AddCommand("worktree")
-	commandBuilder.AddArgs([]string{"remove", w.Path})
-	_, err := commandBuilder.Exec()
+	return w.RemoveContext(context.Background())
+}
+
+func (w Worktree) RemoveContext(ctx context.Context) error {
+	return runWorktreeCommand(ctx, w.Path, []string{"remove", w.Path})
+}
+
+// RemoveForce removes the worktree even if it has local modifications or is locked.
+func (w Worktree) RemoveForce() error {
+	return w.RemoveForceContext(context.Background())
+}
+
+func (w Worktree) RemoveForceContext(ctx context.Context) error {
+	return runWorktreeCommand(ctx, w.Path, []string{"remove", "--force", "--force", w.Path})
+}
+
+// Lock protects the worktree from being pruned, moved or removed.
+func (w *Worktree) Lock(reason string) error {
+	return w.LockContext(context.Background(), reason)
+}
+
+func (w *Worktree) LockContext(ctx context.Context, reason string) error {
+	err := runWorktreeCommand(ctx, w.Path, lockWorktreeArgs(w.Path, reason))
+	if err != nil {
+		return err
+	}
+	w.Locked = true
+	w.LockReason = reason
+	return nil
+}
+
+func (w *Worktree) Unlock() error {
+	return w.UnlockContext(context.Background())
+}
+
+func (w *Worktree) UnlockContext(ctx context.Context) error {
+	err := runWorktreeCommand(ctx, w.Path, []string{"unlock", w.Path})
+	if err != nil {
+		return err
+	}
+	w.Locked = false
+	w.LockReason = ""
+	return nil
+}
+
+func (w *Worktree) Move(newPath string) error {
+	return w.MoveContext(context.Background(), newPath)
+}
+
+func (w *Worktree) MoveContext(ctx context.Context, newPath string) error {
+	err := runWorktreeCommand(ctx, w.Path, []string{"move", w.Path, newPath})
 	if err != nil {
 		return err
 	}
+	w.Path = newPath
 	return nil
 }
 
+// Repair fixes the link between this worktree and its repository, e.g. after
+// the worktree directory was moved without `git worktree move`.
+func (w Worktree) Repair() error {
+	return w.RepairContext(context.Background())
+}
+
+func (w Worktree) RepairContext(ctx context.Context) error {
+	return runWorktreeCommand(ctx, w.Path, []string{"repair"})
+}
+
+type WorktreeOptions struct {
+	// NoCheckout creates the worktree without populating it.
+	NoCheckout bool
+	// SparsePaths restricts the checkout to these directories using a cone
+	// mode sparse-checkout. Files at the root are always checked out.
+	SparsePaths []string
+}
+
+func (o *WorktreeOptions) noCheckout() bool {
+	return o != nil && (o.NoCheckout || len(o.SparsePaths) > 0)
+}
+
+func (w Worktree) SetSparseCheckout(paths []string) error {
+	return w.SetSparseCheckoutContext(context.Background(), paths)
+}
+
+// SetSparseCheckoutContext restricts the worktree to paths in cone mode and
+// checks them out, also when the worktree was added with NoCheckout.
+func (w Worktree) SetSparseCheckoutContext(ctx context.Context, paths []string) error {
+	commandBuilder := commandBuilderFunc()
+	commandBuilder.SetDir(w.Path)
+	commandBuilder.AddCommand("sparse-checkout")
+	commandBuilder.AddArgs(append([]string{"set", "--cone", "--"}, paths...))
+	if _, err := commandBuilder.ExecContext(ctx); err != nil {
+		return err
+	}
+	// sparse-checkout only updates files already in the index, which is
+	// empty after --no-checkout
+	commandBuilder = commandBuilderFunc()
+	commandBuilder.SetDir(w.Path)
+	commandBuilder.AddCommand("read-tree")
+	commandBuilder.AddArgs([]string{"-mu", "HEAD"})
+	_, err := commandBuilder.ExecContext(ctx)
+	return err
+}
+
+func lockWorktreeArgs(path string, reason string) []string {
+	args := []string{"lock"}
+	if reason != "" {
+		args = append(args, "--reason", reason)
+	}
+	return append(args, path)
+}
+
+func pruneWorktreeArgs(expire time.Duration) []string {
+	args := []string{"prune"}
+	if expire > 0 {
+		args = append(args, fmt.Sprintf("--expire=%d.seconds.ago", int64(expire.Seconds())))
+	}
+	return args
+}
+
+func runWorktreeCommand(ctx context.Context, dir string, args []string) error {
+	commandBuilder := commandBuilderFunc()
+	commandBuilder.SetDir(dir)
+	commandBuilder.AddCommand("worktree")
+	commandBuilder.AddArgs(args)
+	_, err := commandBuilder.ExecContext(ctx)
+	return err
+}
+
 func ListWorktree(path string) ([]Worktree, error) {
+	return ListWorktreeContext(context.Background(), path)
+}
+
+func ListWorktreeContext(ctx context.Context, path string) ([]Worktree, error) {
 	commandBuilder := commandBuilderFunc()
 	commandBuilder.AddCommand("worktree")
-	commandBuilder.AddArg("list")
+	commandBuilder.AddArgs([]string{"list", "--porcelain", "-z"})
 	commandBuilder.SetDir(path)
-	output, err := commandBuilder.Exec()
+	output, err := commandBuilder.ExecContext(ctx)
 	if err != nil {
 		return nil, err
 	}
-	formatedOutput := strings.Split(output, "\n")
-	worktrees := lo.FilterMap(formatedOutput, func(s string, i int) (Worktree, bool) {
-		trimmed := strings.Trim(s, " ")
-		if len(trimmed) == 0 {
-			return Worktree{}, false
+	return ParseWorktreePorcelain(output), nil
+}
+
+// ParseWorktreePorcelain parses the output of `git worktree list --porcelain -z`.
+// Every attribute is NUL terminated and worktrees are separated by an empty
+// attribute. The first worktree is always the main one.
+func ParseWorktreePorcelain(output string) []Worktree {
+	worktrees := []Worktree{}
+	var current *Worktree
+	flush := func() {
+		if current != nil {
+			current.IsMain = len(worktrees) == 0
+			worktrees = append(worktrees, *current)
+			current = nil
+		}
+	}
+	for _, attribute := range strings.Split(output, "\x00") {
+		if attribute == "" {
+			flush()
+			continue
 		}
-		w := GenerateWorktree(trimmed)
-		if i == 0 {
-			w.IsMain = true
+		key, value, _ := strings.Cut(attribute, " ")
+		if key == "worktree" {
+			flush()
+			current = &Worktree{Path: value}
+			continue
 		}
-		return w, true
-	})
-	return worktrees, nil
-}
-
-func GenerateWorktree(row string) Worktree {
-	splitWord := []string{}
-	word := ""
-	for i := 0; i < len(row); i++ {
-		ch := string(row[i])
-		if ch == " " {
-			if len(word) > 0 {
-				splitWord = append(splitWord, word)
-				word = ""
-			}
+		if current == nil {
 			continue
 		}
-		word += ch
-		if i == len(row)-1 {
-			if len(word) > 0 {
-				splitWord = append(splitWord, word)
-				word = ""
-			}
+		switch key {
+		case "HEAD":
+			current.CommitSHA = value
+		case "branch":
+			current.Branch = strings.TrimPrefix(value, "refs/heads/")
+		case "detached":
+			current.Detached = true
+		case "bare":
+			current.Bare = true
+		case "locked":
+			current.Locked = true
+			current.LockReason = value
+		case "prunable":
+			current.Prunable = true
+			current.PrunableReason = value
 		}
 	}
-	return Worktree{
-		Path:      splitWord[0],
-		CommitSHA: splitWord[1],
-	}
+	flush()
+	return worktrees
 }
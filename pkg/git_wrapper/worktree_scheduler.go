@@ -4,73 +4,356 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"os"
 	"sync"
 	"time"
 )
 
+const (
+	DefaultMaxWorktreeConcurrency = 4
+	DefaultWorktreeQueueSize      = 256
+	DefaultWorktreeRequestTimeout = 20 * time.Minute
+	defaultMaxCompletedWorktrees  = 1024
+)
+
+var ErrSchedulerBusy = errors.New("worktree scheduler busy")
+
+// SchedulerBusyError is returned when the scheduler queue is full. It matches
+// ErrSchedulerBusy through errors.Is.
+type SchedulerBusyError struct {
+	RootDest  string
+	QueueSize int
+}
+
+func (e *SchedulerBusyError) Error() string {
+	return fmt.Sprintf("%s: %d requests queued, rejecting %s", ErrSchedulerBusy.Error(), e.QueueSize, e.RootDest)
+}
+
+func (e *SchedulerBusyError) Is(target error) bool {
+	return target == ErrSchedulerBusy
+}
+
 var (
 	worktreeScheduler           *WorktreeScheduler
 	createWorktreeSchedulerOnce sync.Once
 )
 
 type WorktreeRequest struct {
+	Context        context.Context
 	ResultChan     chan *Worktree
-	ErrorChan      chan string
+	ErrorChan      chan error
 	RootDest       string
 	CommitSHA      string
 	FullSourcePath string
 }
 
+// WorktreeScheduler serialises worktree operations per root repository, since
+// git holds a lock on the worktree metadata, while letting different
+// repositories proceed in parallel up to maxConcurrency.
 type WorktreeScheduler struct {
-	worktreeRequestChan chan *WorktreeRequest
+	mu             sync.Mutex
+	queues         map[string][]*WorktreeRequest
+	inflight       map[string]*worktreeCall
+	completed      map[string]*Worktree
+	pending        int
+	slots          chan struct{}
+	maxConcurrency int
+	queueSize      int
+	requestTimeout time.Duration
+	// maxCompleted bounds the number of created worktrees remembered for reuse.
+	maxCompleted   int
+	createWorktree func(ctx context.Context, rootDest string, path string, commitSHA string) (*Worktree, error)
+}
+
+// worktreeCall is a worktree creation shared by every caller asking for the
+// same root repository and commit while it is in flight.
+type worktreeCall struct {
+	done     chan struct{}
+	request  *WorktreeRequest
+	cancel   context.CancelFunc
+	waiters  int
+	worktree *Worktree
+	err      error
+}
+
+type WorktreeSchedulerOption func(*WorktreeScheduler)
+
+// WithMaxConcurrency caps the number of repositories processed at the same time.
+func WithMaxConcurrency(n int) WorktreeSchedulerOption {
+	return func(w *WorktreeScheduler) {
+		if n > 0 {
+			w.maxConcurrency = n
+		}
+	}
+}
+
+// WithQueueSize bounds the number of requests waiting across all repositories.
+// Requests beyond it fail fast with a SchedulerBusyError.
+func WithQueueSize(n int) WorktreeSchedulerOption {
+	return func(w *WorktreeScheduler) {
+		if n > 0 {
+			w.queueSize = n
+		}
+	}
 }
 
+// WithRequestTimeout bounds how long a request may wait and run in total.
+func WithRequestTimeout(timeout time.Duration) WorktreeSchedulerOption {
+	return func(w *WorktreeScheduler) {
+		if timeout > 0 {
+			w.requestTimeout = timeout
+		}
+	}
+}
+
+// NewWorktreeScheduler returns the process wide scheduler.
 func NewWorktreeScheduler() *WorktreeScheduler {
 	if worktreeScheduler == nil {
 		createWorktreeSchedulerOnce.Do(func() {
-			worktreeScheduler = &WorktreeScheduler{
-				worktreeRequestChan: make(chan *WorktreeRequest),
-			}
-			go worktreeScheduler.WorktreeConsumer()
+			worktreeScheduler = NewWorktreeSchedulerWithOptions()
 		})
 	}
 	return worktreeScheduler
 }
 
+func NewWorktreeSchedulerWithOptions(opts ...WorktreeSchedulerOption) *WorktreeScheduler {
+	w := &WorktreeScheduler{
+		queues:         make(map[string][]*WorktreeRequest),
+		inflight:       make(map[string]*worktreeCall),
+		completed:      make(map[string]*Worktree),
+		maxConcurrency: DefaultMaxWorktreeConcurrency,
+		queueSize:      DefaultWorktreeQueueSize,
+		requestTimeout: DefaultWorktreeRequestTimeout,
+		maxCompleted:   defaultMaxCompletedWorktrees,
+		createWorktree: createWorktree,
+	}
+	for _, opt := range opts {
+		opt(w)
+	}
+	w.slots = make(chan struct{}, w.maxConcurrency)
+	return w
+}
+
+// createWorktree only lists the worktrees of rootDest, which AddWorktree needs
+// to reuse one, rather than loading the whole repository state while holding
+// a slot.
+func createWorktree(ctx context.Context, rootDest string, path string, commitSHA string) (*Worktree, error) {
+	worktrees, err := listWorktreeFunc(ctx, rootDest)
+	if err != nil {
+		return nil, fmt.Errorf("Load root worktree fail %w", err)
+	}
+	rootRepository := NewRepository("", rootDest)
+	rootRepository.Worktrees = worktrees
+	worktree, err := rootRepository.AddWorktreeContext(ctx, path, commitSHA)
+	if err != nil {
+		return nil, fmt.Errorf("Create worktree fail %w", err)
+	}
+	return worktree, nil
+}
+
+// worktreeKey identifies the worktree at path of rootDest at commitSHA. The
+// path is part of it so that no caller is handed a worktree at another path,
+// which its creator may remove.
+func worktreeKey(rootDest string, commitSHA string, path string) string {
+	return rootDest + "\x00" + commitSHA + "\x00" + path
+}
+
+// CreateWorktree creates a worktree of rootDest at commitSHA in
+// fullSourcePath. Concurrent requests for the same rootDest, commitSHA and
+// fullSourcePath share a single git invocation, and a worktree created earlier
+// for them is reused while it still exists on disk. Every caller gets its own
+// copy of the Worktree.
 func (w *WorktreeScheduler) CreateWorktree(ctx context.Context, fullSourcePath string, commitSHA string, rootDest string) (*Worktree, error) {
-	resultChan := make(chan *Worktree)
-	errorChan := make(chan string)
-	createWorktreeRequest := WorktreeRequest{
-		FullSourcePath: fullSourcePath,
-		CommitSHA:      commitSHA,
-		ResultChan:     resultChan,
-		ErrorChan:      errorChan,
-		RootDest:       rootDest,
-	}
-	w.worktreeRequestChan <- &createWorktreeRequest
+	ctx, cancel := context.WithTimeout(ctx, w.requestTimeout)
+	defer cancel()
+	for {
+		worktree, call, err := w.joinCall(fullSourcePath, commitSHA, rootDest)
+		if worktree != nil || err != nil {
+			return worktree, err
+		}
+		select {
+		case <-call.done:
+			if errors.Is(call.err, context.Canceled) && ctx.Err() == nil {
+				// joined a call its other waiters had just given up on
+				continue
+			}
+			if call.err != nil {
+				return nil, call.err
+			}
+			created := *call.worktree
+			return &created, nil
+		case <-ctx.Done():
+			w.leaveCall(call)
+			if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+				return nil, fmt.Errorf("Create worktree timeout %w", ctx.Err())
+			}
+			return nil, ctx.Err()
+		}
+	}
+}
+
+// joinCall returns a copy of the completed worktree for rootDest, commitSHA
+// and fullSourcePath, or the in-flight call creating it, started if needed.
+func (w *WorktreeScheduler) joinCall(fullSourcePath string, commitSHA string, rootDest string) (*Worktree, *worktreeCall, error) {
+	key := worktreeKey(rootDest, commitSHA, fullSourcePath)
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if worktree, found := w.completed[key]; found {
+		if _, err := os.Stat(worktree.Path); err == nil {
+			worktree := *worktree
+			return &worktree, nil, nil
+		}
+		delete(w.completed, key)
+	}
+	call, found := w.inflight[key]
+	if !found {
+		// The shared request outlives any single caller, it is only cancelled
+		// once every waiter has given up.
+		callCtx, cancel := context.WithTimeout(context.Background(), w.requestTimeout)
+		request := &WorktreeRequest{
+			Context:        callCtx,
+			FullSourcePath: fullSourcePath,
+			CommitSHA:      commitSHA,
+			// Buffered so the consumer never blocks on a caller that already gave up.
+			ResultChan: make(chan *Worktree, 1),
+			ErrorChan:  make(chan error, 1),
+			RootDest:   rootDest,
+		}
+		if err := w.enqueueLocked(request); err != nil {
+			cancel()
+			return nil, nil, err
+		}
+		call = &worktreeCall{
+			done:    make(chan struct{}),
+			request: request,
+			cancel:  cancel,
+		}
+		w.inflight[key] = call
+		go w.awaitCall(key, call)
+	}
+	call.waiters++
+	return nil, call, nil
+}
+
+// awaitCall publishes the outcome of a shared request to its waiters.
+func (w *WorktreeScheduler) awaitCall(key string, call *worktreeCall) {
+	defer call.cancel()
+	request := call.request
 	select {
-	case worktree := <-resultChan:
-		return worktree, nil
-	case <-time.After(20 * time.Minute):
-		return nil, errors.New("Create worktree timeout")
-	case err := <-errorChan:
-		return nil, errors.New(err)
+	case call.worktree = <-request.ResultChan:
+	case call.err = <-request.ErrorChan:
+	case <-request.Context.Done():
+		select {
+		case call.worktree = <-request.ResultChan:
+		case call.err = <-request.ErrorChan:
+		default:
+			call.err = request.Context.Err()
+		}
+	}
+	w.mu.Lock()
+	delete(w.inflight, key)
+	if call.err == nil && call.worktree != nil {
+		w.addCompletedLocked(key, call.worktree)
 	}
+	w.mu.Unlock()
+	close(call.done)
 }
 
-func (w *WorktreeScheduler) WorktreeConsumer() {
+// addCompletedLocked remembers a created worktree. Once maxCompleted worktrees
+// are remembered, the ones gone from disk are forgotten and, if that isn't
+// enough, an arbitrary one. w.mu must be held.
+func (w *WorktreeScheduler) addCompletedLocked(key string, worktree *Worktree) {
+	if _, found := w.completed[key]; !found && len(w.completed) >= w.maxCompleted {
+		for completedKey, completed := range w.completed {
+			if _, err := os.Stat(completed.Path); err != nil {
+				delete(w.completed, completedKey)
+			}
+		}
+		for completedKey := range w.completed {
+			if len(w.completed) < w.maxCompleted {
+				break
+			}
+			delete(w.completed, completedKey)
+		}
+	}
+	w.completed[key] = worktree
+}
+
+// leaveCall drops a waiter and cancels the shared request once nobody waits.
+func (w *WorktreeScheduler) leaveCall(call *worktreeCall) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	call.waiters--
+	if call.waiters > 0 {
+		return
+	}
+	w.dequeueLocked(call.request)
+	call.cancel()
+}
+
+// enqueueLocked appends the request to the queue of its root repository and
+// starts a worker for that repository if none is running. w.mu must be held.
+func (w *WorktreeScheduler) enqueueLocked(request *WorktreeRequest) error {
+	if w.pending >= w.queueSize {
+		return &SchedulerBusyError{RootDest: request.RootDest, QueueSize: w.pending}
+	}
+	queue, running := w.queues[request.RootDest]
+	w.queues[request.RootDest] = append(queue, request)
+	w.pending++
+	if !running {
+		go w.worktreeConsumer(request.RootDest)
+	}
+	return nil
+}
+
+// dequeueLocked drops a request that is still waiting in its queue. w.mu must
+// be held.
+func (w *WorktreeScheduler) dequeueLocked(request *WorktreeRequest) {
+	queue := w.queues[request.RootDest]
+	for i, queued := range queue {
+		if queued == request {
+			w.queues[request.RootDest] = append(queue[:i:i], queue[i+1:]...)
+			w.pending--
+			return
+		}
+	}
+}
+
+// worktreeConsumer drains the queue of a single root repository and exits
+// once it is empty.
+func (w *WorktreeScheduler) worktreeConsumer(rootDest string) {
 	for {
-		worktreeRequest := <-w.worktreeRequestChan
-		rootRepository, err := Load(worktreeRequest.RootDest)
-		if err != nil {
-			worktreeRequest.ErrorChan <- fmt.Sprintf("Load root worktree fail %s", err.Error())
+		w.mu.Lock()
+		queue := w.queues[rootDest]
+		if len(queue) == 0 {
+			delete(w.queues, rootDest)
+			w.mu.Unlock()
+			return
+		}
+		request := queue[0]
+		w.queues[rootDest] = queue[1:]
+		w.pending--
+		w.mu.Unlock()
+
+		ctx := request.Context
+		if ctx == nil {
+			ctx = context.Background()
+		}
+		select {
+		case w.slots <- struct{}{}:
+		case <-ctx.Done():
+			continue
+		}
+		if ctx.Err() != nil {
+			<-w.slots
 			continue
 		}
-		worktree, err := rootRepository.AddWorktree(worktreeRequest.FullSourcePath, worktreeRequest.CommitSHA)
+		worktree, err := w.createWorktree(ctx, request.RootDest, request.FullSourcePath, request.CommitSHA)
+		<-w.slots
 		if err != nil {
-			worktreeRequest.ErrorChan <- fmt.Sprintf("Create worktree fail %s", err.Error())
+			request.ErrorChan <- err
 			continue
 		}
-		worktreeRequest.ResultChan <- worktree
+		request.ResultChan <- worktree
 	}
 }
@@ -0,0 +1,341 @@
+package git_wrapper
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	mock_git_wrapper "operarius/mock/pkg/git_wrapper"
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/golang/mock/gomock"
+	. "github.com/onsi/ginkgo/v2"
+	. "github.com/onsi/gomega"
+)
+
+type concurrencyRecorder struct {
+	mu      sync.Mutex
+	running map[string]int
+	maxRepo map[string]int
+	total   int32
+	maxAll  int32
+}
+
+func newConcurrencyRecorder() *concurrencyRecorder {
+	return &concurrencyRecorder{running: map[string]int{}, maxRepo: map[string]int{}}
+}
+
+func (r *concurrencyRecorder) createWorktree(ctx context.Context, rootDest string, path string, commitSHA string) (*Worktree, error) {
+	r.mu.Lock()
+	r.running[rootDest]++
+	if r.running[rootDest] > r.maxRepo[rootDest] {
+		r.maxRepo[rootDest] = r.running[rootDest]
+	}
+	r.mu.Unlock()
+	total := atomic.AddInt32(&r.total, 1)
+	for {
+		max := atomic.LoadInt32(&r.maxAll)
+		if total <= max || atomic.CompareAndSwapInt32(&r.maxAll, max, total) {
+			break
+		}
+	}
+	time.Sleep(20 * time.Millisecond)
+	atomic.AddInt32(&r.total, -1)
+	r.mu.Lock()
+	r.running[rootDest]--
+	r.mu.Unlock()
+	w := NewWorkTree(path, commitSHA)
+	return &w, nil
+}
+
+var _ = Describe("WorktreeScheduler unit test", func() {
+	createAll := func(scheduler *WorktreeScheduler, roots []string, perRoot int) {
+		var wg sync.WaitGroup
+		for _, root := range roots {
+			for i := 0; i < perRoot; i++ {
+				wg.Add(1)
+				go func(root string, i int) {
+					defer GinkgoRecover()
+					defer wg.Done()
+					path := fmt.Sprintf("%s/worktree-%d", root, i)
+					worktree, err := scheduler.CreateWorktree(context.Background(), path, fmt.Sprintf("sha-%d", i), root)
+					Expect(err).To(BeNil())
+					Expect(worktree.Path).To(Equal(path))
+				}(root, i)
+			}
+		}
+		wg.Wait()
+	}
+
+	Context("CreateWorktree(ctx context.Context, fullSourcePath string, commitSHA string, rootDest string) (*Worktree, error)", func() {
+		It("Should serialise requests for the same repository and run different repositories in parallel", func() {
+			recorder := newConcurrencyRecorder()
+			scheduler := NewWorktreeSchedulerWithOptions(WithMaxConcurrency(3))
+			scheduler.createWorktree = recorder.createWorktree
+			createAll(scheduler, []string{"/tmp/a", "/tmp/b", "/tmp/c"}, 3)
+			for _, root := range []string{"/tmp/a", "/tmp/b", "/tmp/c"} {
+				Expect(recorder.maxRepo[root]).To(Equal(1))
+			}
+			Expect(atomic.LoadInt32(&recorder.maxAll)).To(BeNumerically(">", 1))
+			Eventually(func() int {
+				scheduler.mu.Lock()
+				defer scheduler.mu.Unlock()
+				return len(scheduler.queues)
+			}).Should(BeZero())
+		})
+
+		It("Should never exceed the global concurrency cap", func() {
+			recorder := newConcurrencyRecorder()
+			scheduler := NewWorktreeSchedulerWithOptions(WithMaxConcurrency(2))
+			scheduler.createWorktree = recorder.createWorktree
+			createAll(scheduler, []string{"/tmp/a", "/tmp/b", "/tmp/c", "/tmp/d"}, 2)
+			Expect(atomic.LoadInt32(&recorder.maxAll)).To(BeNumerically("<=", 2))
+		})
+	})
+
+	Context("Cancellation and backpressure", func() {
+		var release chan struct{}
+		var calls *int32
+		var scheduler *WorktreeScheduler
+		BeforeEach(func() {
+			specRelease := make(chan struct{})
+			specCalls := new(int32)
+			release = specRelease
+			calls = specCalls
+			scheduler = NewWorktreeSchedulerWithOptions(WithMaxConcurrency(1), WithQueueSize(1))
+			scheduler.createWorktree = func(ctx context.Context, rootDest string, path string, commitSHA string) (*Worktree, error) {
+				atomic.AddInt32(specCalls, 1)
+				select {
+				case <-specRelease:
+				case <-ctx.Done():
+					return nil, ctx.Err()
+				}
+				w := NewWorkTree(path, commitSHA)
+				return &w, nil
+			}
+		})
+		AfterEach(func() {
+			close(release)
+		})
+
+		waitRunning := func() {
+			Eventually(func() int32 { return atomic.LoadInt32(calls) }).Should(Equal(int32(1)))
+		}
+
+		It("Should reject requests with a SchedulerBusyError once the queue is full", func() {
+			go scheduler.CreateWorktree(context.Background(), "/tmp/a/1", "sha", "/tmp/a")
+			waitRunning()
+			go scheduler.CreateWorktree(context.Background(), "/tmp/a/2", "sha-2", "/tmp/a")
+			Eventually(func() int {
+				scheduler.mu.Lock()
+				defer scheduler.mu.Unlock()
+				return scheduler.pending
+			}).Should(Equal(1))
+			_, err := scheduler.CreateWorktree(context.Background(), "/tmp/a/3", "sha-3", "/tmp/a")
+			Expect(errors.Is(err, ErrSchedulerBusy)).To(BeTrue())
+			var busyErr *SchedulerBusyError
+			Expect(errors.As(err, &busyErr)).To(BeTrue())
+			Expect(busyErr.RootDest).To(Equal("/tmp/a"))
+		})
+
+		It("Should drop queued requests whose caller has cancelled", func() {
+			go scheduler.CreateWorktree(context.Background(), "/tmp/a/1", "sha", "/tmp/a")
+			waitRunning()
+			ctx, cancel := context.WithCancel(context.Background())
+			done := make(chan error)
+			go func() {
+				_, err := scheduler.CreateWorktree(ctx, "/tmp/a/2", "sha-2", "/tmp/a")
+				done <- err
+			}()
+			Eventually(func() int {
+				scheduler.mu.Lock()
+				defer scheduler.mu.Unlock()
+				return scheduler.pending
+			}).Should(Equal(1))
+			cancel()
+			Expect(<-done).To(Equal(context.Canceled))
+			scheduler.mu.Lock()
+			Expect(scheduler.pending).To(BeZero())
+			scheduler.mu.Unlock()
+			release <- struct{}{}
+			Consistently(func() int32 { return atomic.LoadInt32(calls) }, 50*time.Millisecond).Should(Equal(int32(1)))
+		})
+
+		It("Should time out with the configured request timeout", func() {
+			scheduler.requestTimeout = 20 * time.Millisecond
+			_, err := scheduler.CreateWorktree(context.Background(), "/tmp/a/1", "sha", "/tmp/a")
+			Expect(errors.Is(err, context.DeadlineExceeded)).To(BeTrue())
+		})
+	})
+
+	Context("Deduplication", func() {
+		var calls *int32
+		var scheduler *WorktreeScheduler
+		BeforeEach(func() {
+			specCalls := new(int32)
+			calls = specCalls
+			scheduler = NewWorktreeSchedulerWithOptions()
+			scheduler.createWorktree = func(ctx context.Context, rootDest string, path string, commitSHA string) (*Worktree, error) {
+				atomic.AddInt32(specCalls, 1)
+				select {
+				case <-time.After(100 * time.Millisecond):
+				case <-ctx.Done():
+					return nil, ctx.Err()
+				}
+				if err := os.MkdirAll(path, os.ModePerm); err != nil {
+					return nil, err
+				}
+				w := NewWorkTree(path, commitSHA)
+				return &w, nil
+			}
+		})
+
+		It("Should share one creation between identical in-flight requests", func() {
+			path := filepath.Join(GinkgoT().TempDir(), "worktree")
+			var wg sync.WaitGroup
+			results := make([]*Worktree, 5)
+			for i := range results {
+				wg.Add(1)
+				go func(i int) {
+					defer GinkgoRecover()
+					defer wg.Done()
+					worktree, err := scheduler.CreateWorktree(context.Background(), path, "sha", "/tmp/a")
+					Expect(err).To(BeNil())
+					results[i] = worktree
+				}(i)
+			}
+			wg.Wait()
+			Expect(atomic.LoadInt32(calls)).To(Equal(int32(1)))
+			for _, worktree := range results[1:] {
+				Expect(worktree).To(Equal(results[0]))
+				Expect(worktree).NotTo(BeIdenticalTo(results[0]))
+			}
+		})
+
+		It("Should reuse a completed worktree while it exists on disk", func() {
+			path := filepath.Join(GinkgoT().TempDir(), "worktree")
+			first, err := scheduler.CreateWorktree(context.Background(), path, "sha", "/tmp/a")
+			Expect(err).To(BeNil())
+			second, err := scheduler.CreateWorktree(context.Background(), path, "sha", "/tmp/a")
+			Expect(err).To(BeNil())
+			Expect(second).To(Equal(first))
+			Expect(atomic.LoadInt32(calls)).To(Equal(int32(1)))
+			// callers own their copy
+			first.Path = "/moved"
+			third, err := scheduler.CreateWorktree(context.Background(), path, "sha", "/tmp/a")
+			Expect(err).To(BeNil())
+			Expect(third.Path).To(Equal(path))
+
+			Expect(os.RemoveAll(path)).To(Succeed())
+			_, err = scheduler.CreateWorktree(context.Background(), path, "sha", "/tmp/a")
+			Expect(err).To(BeNil())
+			Expect(atomic.LoadInt32(calls)).To(Equal(int32(2)))
+		})
+
+		It("Should not hand out a worktree of the same commit at another path", func() {
+			dir := GinkgoT().TempDir()
+			first, err := scheduler.CreateWorktree(context.Background(), filepath.Join(dir, "first"), "sha", "/tmp/a")
+			Expect(err).To(BeNil())
+			second, err := scheduler.CreateWorktree(context.Background(), filepath.Join(dir, "second"), "sha", "/tmp/a")
+			Expect(err).To(BeNil())
+			Expect(first.Path).To(Equal(filepath.Join(dir, "first")))
+			Expect(second.Path).To(Equal(filepath.Join(dir, "second")))
+			Expect(atomic.LoadInt32(calls)).To(Equal(int32(2)))
+		})
+
+		It("Should remember a bounded number of completed worktrees", func() {
+			scheduler.maxCompleted = 2
+			dir := GinkgoT().TempDir()
+			for _, sha := range []string{"a", "b", "c"} {
+				_, err := scheduler.CreateWorktree(context.Background(), filepath.Join(dir, sha), sha, "/tmp/a")
+				Expect(err).To(BeNil())
+			}
+			Expect(scheduler.completed).To(HaveLen(2))
+			Expect(scheduler.completed).To(HaveKey(worktreeKey("/tmp/a", "c", filepath.Join(dir, "c"))))
+		})
+
+		It("Should keep the shared creation running when one of the waiters cancels", func() {
+			path := filepath.Join(GinkgoT().TempDir(), "worktree")
+			ctx, cancel := context.WithCancel(context.Background())
+			cancelled := make(chan error)
+			go func() {
+				_, err := scheduler.CreateWorktree(ctx, path, "sha", "/tmp/a")
+				cancelled <- err
+			}()
+			Eventually(func() int32 { return atomic.LoadInt32(calls) }).Should(Equal(int32(1)))
+			done := make(chan *Worktree)
+			go func() {
+				defer GinkgoRecover()
+				worktree, err := scheduler.CreateWorktree(context.Background(), path, "sha", "/tmp/a")
+				Expect(err).To(BeNil())
+				done <- worktree
+			}()
+			Eventually(func() int {
+				scheduler.mu.Lock()
+				defer scheduler.mu.Unlock()
+				if call, found := scheduler.inflight[worktreeKey("/tmp/a", "sha", path)]; found {
+					return call.waiters
+				}
+				return 0
+			}).Should(Equal(2))
+			cancel()
+			Expect(<-cancelled).To(Equal(context.Canceled))
+			Expect((<-done).Path).To(Equal(path))
+			Expect(atomic.LoadInt32(calls)).To(Equal(int32(1)))
+		})
+
+		It("Should start over after joining a call cancelled by its other waiters", func() {
+			path := filepath.Join(GinkgoT().TempDir(), "worktree")
+			key := worktreeKey("/tmp/a", "sha", path)
+			cancelled := &worktreeCall{done: make(chan struct{}), request: &WorktreeRequest{RootDest: "/tmp/a"}, cancel: func() {}}
+			scheduler.mu.Lock()
+			scheduler.inflight[key] = cancelled
+			scheduler.mu.Unlock()
+			done := make(chan *Worktree)
+			go func() {
+				defer GinkgoRecover()
+				worktree, err := scheduler.CreateWorktree(context.Background(), path, "sha", "/tmp/a")
+				Expect(err).To(BeNil())
+				done <- worktree
+			}()
+			Eventually(func() int {
+				scheduler.mu.Lock()
+				defer scheduler.mu.Unlock()
+				return cancelled.waiters
+			}).Should(Equal(1))
+			scheduler.mu.Lock()
+			delete(scheduler.inflight, key)
+			cancelled.err = context.Canceled
+			scheduler.mu.Unlock()
+			close(cancelled.done)
+			Expect((<-done).Path).To(Equal(path))
+			Expect(atomic.LoadInt32(calls)).To(Equal(int32(1)))
+		})
+	})
+
+	Context("createWorktree(ctx context.Context, rootDest string, path string, commitSHA string) (*Worktree, error)", func() {
+		It("Should only list the worktrees of the root before adding one", func() {
+			mockCtrl := gomock.NewController(GinkgoT())
+			mockCommandBuilder := mock_git_wrapper.NewMockICommandBuilder(mockCtrl)
+			DeferCleanup(func(old func() ICommandBuilder) { commandBuilderFunc = old }, commandBuilderFunc)
+			commandBuilderFunc = func() ICommandBuilder {
+				return mockCommandBuilder
+			}
+			DeferCleanup(func(old func(context.Context, string) ([]Worktree, error)) { listWorktreeFunc = old }, listWorktreeFunc)
+			listWorktreeFunc = func(ctx context.Context, path string) ([]Worktree, error) {
+				return []Worktree{{Path: "/tmp/a"}}, nil
+			}
+			mockCommandBuilder.EXPECT().SetDir("/tmp/a")
+			mockCommandBuilder.EXPECT().AddCommand("worktree")
+			mockCommandBuilder.EXPECT().AddArgs([]string{"add", "/tmp/a-sha"})
+			mockCommandBuilder.EXPECT().AddArg("ebc635a")
+			mockCommandBuilder.EXPECT().ExecContext(gomock.Any()).Return("", nil)
+			worktree, err := createWorktree(context.Background(), "/tmp/a", "/tmp/a-sha", "ebc635a")
+			Expect(err).To(BeNil())
+			Expect(worktree.Path).To(Equal("/tmp/a-sha"))
+		})
+	})
+})
@@ -1,7 +1,9 @@
 package git_wrapper
 
 import (
+	"errors"
 	mock_git_wrapper "operarius/mock/pkg/git_wrapper"
+	"path/filepath"
 
 	"github.com/golang/mock/gomock"
 	. "github.com/onsi/ginkgo/v2"
@@ -22,20 +24,45 @@ var _ = Describe("Worktree unit test", func() {
 	AfterEach(func() {
 		defer func() { commandBuilderFunc = old }()
 	})
-	Context("GenerateWorktree(row string)", func() {
-		It("Should return correct Worktree struct", func() {
-			result := GenerateWorktree(`/tmp/operarius  acde210 [issue-SS192-golang-git-package]`)
-			Expect(result).Should(Equal(Worktree{
-				CommitSHA: "acde210",
-				Path:      "/tmp/operarius",
+	Context("ParseWorktreePorcelain(output string) []Worktree", func() {
+		It("Should parse branch, detached, bare, locked and prunable worktrees", func() {
+			output := "worktree /tmp/operarius\x00HEAD 74580d7a4c2b0e4a1f8c5e2d7b9a3c6f1e0d2b4a\x00branch refs/heads/main\x00\x00" +
+				"worktree /tmp/my worktree\x00HEAD acde2105f1b3c7d9e2a4b6c8d0e1f3a5b7c9d1e3\x00detached\x00locked scan in progress\x00\x00" +
+				"worktree /tmp/gone\x00HEAD 1111111111111111111111111111111111111111\x00branch refs/heads/feature/x\x00locked\x00prunable gitdir file points to non-existent location\x00\x00"
+			result := ParseWorktreePorcelain(output)
+			Expect(result).Should(Equal([]Worktree{
+				{
+					Path:      "/tmp/operarius",
+					CommitSHA: "74580d7a4c2b0e4a1f8c5e2d7b9a3c6f1e0d2b4a",
+					Branch:    "main",
+					IsMain:    true,
+				},
+				{
+					Path:       "/tmp/my worktree",
+					CommitSHA:  "acde2105f1b3c7d9e2a4b6c8d0e1f3a5b7c9d1e3",
+					Detached:   true,
+					Locked:     true,
+					LockReason: "scan in progress",
+				},
+				{
+					Path:           "/tmp/gone",
+					CommitSHA:      "1111111111111111111111111111111111111111",
+					Branch:         "feature/x",
+					Locked:         true,
+					Prunable:       true,
+					PrunableReason: "gitdir file points to non-existent location",
+				},
 			}))
 		})
 
-		It("Should return correct Worktree struct", func() {
-			result := GenerateWorktree(`/tmp/operarius-2  123123 [kai-branch]`)
-			Expect(result).Should(Equal(Worktree{
-				CommitSHA: "123123",
-				Path:      "/tmp/operarius-2",
+		It("Should parse a bare main repository", func() {
+			result := ParseWorktreePorcelain("worktree /tmp/core-api.git\x00bare\x00\x00")
+			Expect(result).Should(Equal([]Worktree{
+				{
+					Path:   "/tmp/core-api.git",
+					Bare:   true,
+					IsMain: true,
+				},
 			}))
 		})
 	})
@@ -43,19 +70,22 @@ var _ = Describe("Worktree unit test", func() {
 	Context("ListWorktree(path string) ([]Worktree, error)", func() {
 		It("Should return list worktrees", func() {
 			mockCommandBuilder.EXPECT().AddCommand("worktree")
-			mockCommandBuilder.EXPECT().AddArg("list")
+			mockCommandBuilder.EXPECT().AddArgs([]string{"list", "--porcelain", "-z"})
 			mockCommandBuilder.EXPECT().SetDir("./tmp/core-api")
-			mockCommandBuilder.EXPECT().Exec().Return("/tmp/operarius  74580d7 [issue-SS192-golang-git-package]\n"+"/tmp/operarius/kai-test  acde210 [issue-SS192-golang-git-package]", nil)
+			mockCommandBuilder.EXPECT().ExecContext(gomock.Any()).Return("worktree /tmp/operarius\x00HEAD 74580d7a4c2b0e4a1f8c5e2d7b9a3c6f1e0d2b4a\x00branch refs/heads/issue-SS192-golang-git-package\x00\x00"+
+				"worktree /tmp/operarius/kai-test\x00HEAD acde2105f1b3c7d9e2a4b6c8d0e1f3a5b7c9d1e3\x00branch refs/heads/kai-branch\x00\x00", nil)
 			result, _ := ListWorktree("./tmp/core-api")
 			Expect(result).Should(Equal([]Worktree{
 				{
-					CommitSHA: "74580d7",
+					CommitSHA: "74580d7a4c2b0e4a1f8c5e2d7b9a3c6f1e0d2b4a",
 					Path:      "/tmp/operarius",
+					Branch:    "issue-SS192-golang-git-package",
 					IsMain:    true,
 				},
 				{
-					CommitSHA: "acde210",
+					CommitSHA: "acde2105f1b3c7d9e2a4b6c8d0e1f3a5b7c9d1e3",
 					Path:      "/tmp/operarius/kai-test",
+					Branch:    "kai-branch",
 					IsMain:    false,
 				},
 			}))
@@ -63,17 +93,135 @@ var _ = Describe("Worktree unit test", func() {
 
 		It("Should return only main worktree if the list is one", func() {
 			mockCommandBuilder.EXPECT().AddCommand("worktree")
-			mockCommandBuilder.EXPECT().AddArg("list")
+			mockCommandBuilder.EXPECT().AddArgs([]string{"list", "--porcelain", "-z"})
 			mockCommandBuilder.EXPECT().SetDir("./tmp/core-api")
-			mockCommandBuilder.EXPECT().Exec().Return("/tmp/operarius  74580d7 [issue-SS192-golang-git-package]", nil)
+			mockCommandBuilder.EXPECT().ExecContext(gomock.Any()).Return("worktree /tmp/operarius\x00HEAD 74580d7a4c2b0e4a1f8c5e2d7b9a3c6f1e0d2b4a\x00branch refs/heads/issue-SS192-golang-git-package\x00\x00", nil)
 			result, _ := ListWorktree("./tmp/core-api")
 			Expect(result).Should(Equal([]Worktree{
 				{
-					CommitSHA: "74580d7",
+					CommitSHA: "74580d7a4c2b0e4a1f8c5e2d7b9a3c6f1e0d2b4a",
 					Path:      "/tmp/operarius",
+					Branch:    "issue-SS192-golang-git-package",
 					IsMain:    true,
 				},
 			}))
 		})
 	})
+
+	Context("Lock(reason string) error", func() {
+		It("Should lock the worktree with a reason", func() {
+			worktree := NewWorkTree("/tmp/operarius/kai-test", "acde210")
+			mockCommandBuilder.EXPECT().SetDir("/tmp/operarius/kai-test")
+			mockCommandBuilder.EXPECT().AddCommand("worktree")
+			mockCommandBuilder.EXPECT().AddArgs([]string{"lock", "--reason", "scan in progress", "/tmp/operarius/kai-test"})
+			mockCommandBuilder.EXPECT().ExecContext(gomock.Any()).Return("", nil)
+			Expect(worktree.Lock("scan in progress")).Should(Succeed())
+			Expect(worktree.Locked).Should(BeTrue())
+			Expect(worktree.LockReason).Should(Equal("scan in progress"))
+		})
+	})
+
+	Context("Unlock() error", func() {
+		It("Should unlock the worktree", func() {
+			worktree := Worktree{Path: "/tmp/operarius/kai-test", Locked: true, LockReason: "scan in progress"}
+			mockCommandBuilder.EXPECT().SetDir("/tmp/operarius/kai-test")
+			mockCommandBuilder.EXPECT().AddCommand("worktree")
+			mockCommandBuilder.EXPECT().AddArgs([]string{"unlock", "/tmp/operarius/kai-test"})
+			mockCommandBuilder.EXPECT().ExecContext(gomock.Any()).Return("", nil)
+			Expect(worktree.Unlock()).Should(Succeed())
+			Expect(worktree.Locked).Should(BeFalse())
+			Expect(worktree.LockReason).Should(BeEmpty())
+		})
+	})
+
+	Context("Move(newPath string) error", func() {
+		It("Should move the worktree and update its path", func() {
+			worktree := NewWorkTree("/tmp/operarius/kai-test", "acde210")
+			mockCommandBuilder.EXPECT().SetDir("/tmp/operarius/kai-test")
+			mockCommandBuilder.EXPECT().AddCommand("worktree")
+			mockCommandBuilder.EXPECT().AddArgs([]string{"move", "/tmp/operarius/kai-test", "/tmp/operarius/kai-moved"})
+			mockCommandBuilder.EXPECT().ExecContext(gomock.Any()).Return("", nil)
+			Expect(worktree.Move("/tmp/operarius/kai-moved")).Should(Succeed())
+			Expect(worktree.Path).Should(Equal("/tmp/operarius/kai-moved"))
+		})
+
+		It("Should keep the old path when git fails", func() {
+			worktree := NewWorkTree("/tmp/operarius/kai-test", "acde210")
+			mockCommandBuilder.EXPECT().SetDir("/tmp/operarius/kai-test")
+			mockCommandBuilder.EXPECT().AddCommand("worktree")
+			mockCommandBuilder.EXPECT().AddArgs([]string{"move", "/tmp/operarius/kai-test", "/tmp/operarius/kai-moved"})
+			mockCommandBuilder.EXPECT().ExecContext(gomock.Any()).Return("", errors.New("Exec Error"))
+			Expect(worktree.Move("/tmp/operarius/kai-moved")).ShouldNot(Succeed())
+			Expect(worktree.Path).Should(Equal("/tmp/operarius/kai-test"))
+		})
+	})
+
+	Context("RemoveForce() error", func() {
+		It("Should remove the worktree even if it is dirty or locked", func() {
+			worktree := NewWorkTree("/tmp/operarius/kai-test", "acde210")
+			mockCommandBuilder.EXPECT().SetDir("/tmp/operarius/kai-test")
+			mockCommandBuilder.EXPECT().AddCommand("worktree")
+			mockCommandBuilder.EXPECT().AddArgs([]string{"remove", "--force", "--force", "/tmp/operarius/kai-test"})
+			mockCommandBuilder.EXPECT().ExecContext(gomock.Any()).Return("", nil)
+			Expect(worktree.RemoveForce()).Should(Succeed())
+		})
+	})
+
+	Context("AddWorktreeWithOptions(path string, commitSHA string, option *WorktreeOptions) (*Worktree, error)", func() {
+		It("Should remove the worktree when the sparse checkout fails", func() {
+			repository := NewRepository("", "/tmp/root")
+			gomock.InOrder(
+				mockCommandBuilder.EXPECT().SetDir("/tmp/root"),
+				mockCommandBuilder.EXPECT().AddCommand("worktree"),
+				mockCommandBuilder.EXPECT().AddArgs([]string{"add", "--no-checkout", "/tmp/wt"}),
+				mockCommandBuilder.EXPECT().AddArg("abc"),
+				mockCommandBuilder.EXPECT().ExecContext(gomock.Any()),
+				mockCommandBuilder.EXPECT().SetDir("/tmp/wt"),
+				mockCommandBuilder.EXPECT().AddCommand("sparse-checkout"),
+				mockCommandBuilder.EXPECT().AddArgs([]string{"set", "--cone", "--", "src"}),
+				mockCommandBuilder.EXPECT().ExecContext(gomock.Any()).Return("", errors.New("sparse-checkout failed")),
+				mockCommandBuilder.EXPECT().SetDir("/tmp/root"),
+				mockCommandBuilder.EXPECT().AddCommand("worktree"),
+				mockCommandBuilder.EXPECT().AddArgs([]string{"remove", "--force", "--force", "/tmp/wt"}),
+				mockCommandBuilder.EXPECT().ExecContext(gomock.Any()),
+			)
+			_, err := repository.AddWorktreeWithOptions("/tmp/wt", "abc", &WorktreeOptions{SparsePaths: []string{"src"}})
+			Expect(err).ShouldNot(BeNil())
+			Expect(repository.Worktrees).Should(BeEmpty())
+		})
+	})
+})
+
+var _ = Describe("Sparse worktree test", func() {
+	It("Should only check out the sparse paths", func() {
+		dest := initTestRepository(map[string]string{
+			"README":          "root",
+			"src/app/main.go": "package main",
+			"docs/guide.md":   "guide",
+		})
+		repository := NewRepository("", dest)
+		path := filepath.Join(GinkgoT().TempDir(), "sparse")
+		worktree, err := repository.AddWorktreeWithOptions(path, "HEAD", &WorktreeOptions{SparsePaths: []string{"src"}})
+		Expect(err).Should(BeNil())
+		Expect(worktree.Path).Should(Equal(path))
+		Expect(filepath.Join(path, "README")).Should(BeAnExistingFile())
+		Expect(filepath.Join(path, "src/app/main.go")).Should(BeAnExistingFile())
+		Expect(filepath.Join(path, "docs")).ShouldNot(BeAnExistingFile())
+		Expect(runTestGit(path, "status", "--porcelain")).Should(BeEmpty())
+	})
+
+	It("Should leave a no-checkout worktree empty until sparse paths are set", func() {
+		dest := initTestRepository(map[string]string{
+			"src/main.go":   "package main",
+			"docs/guide.md": "guide",
+		})
+		repository := NewRepository("", dest)
+		path := filepath.Join(GinkgoT().TempDir(), "empty")
+		worktree, err := repository.AddWorktreeWithOptions(path, "HEAD", &WorktreeOptions{NoCheckout: true})
+		Expect(err).Should(BeNil())
+		Expect(filepath.Join(path, "src")).ShouldNot(BeAnExistingFile())
+		Expect(worktree.SetSparseCheckout([]string{"docs"})).Should(Succeed())
+		Expect(filepath.Join(path, "docs/guide.md")).Should(BeAnExistingFile())
+		Expect(filepath.Join(path, "src")).ShouldNot(BeAnExistingFile())
+	})
 })